@@ -0,0 +1,32 @@
+package fireql
+
+import (
+	"github.com/pgollangi/fireql/pkg/util"
+)
+
+// Errors returned by Execute can be inspected with errors.As, e.g.
+//
+//	var parseErr *fireql.ParseError
+//	if errors.As(err, &parseErr) {
+//		fmt.Println(parseErr.Code, parseErr.Position, parseErr.Fragment)
+//	}
+type (
+	// ParseError is returned when the query is malformed.
+	ParseError = util.ParseError
+	// UnsupportedError is returned when the query is valid SQL FireQL can't run on Firestore.
+	UnsupportedError = util.UnsupportedError
+	// FirestoreError is returned when Firestore fails to run the query.
+	FirestoreError = util.FirestoreError
+	// ErrorCode identifies the reason of a ParseError or an UnsupportedError.
+	ErrorCode = util.ErrorCode
+)
+
+const (
+	CodeSyntaxError           = util.CodeSyntaxError
+	CodeInvalidArgument       = util.CodeInvalidArgument
+	CodeUnsupportedStatement  = util.CodeUnsupportedStatement
+	CodeUnsupportedClause     = util.CodeUnsupportedClause
+	CodeUnsupportedExpression = util.CodeUnsupportedExpression
+	CodeUnsupportedFunction   = util.CodeUnsupportedFunction
+	CodeQueryLimitation       = util.CodeQueryLimitation
+)
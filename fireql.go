@@ -1,10 +1,16 @@
 package fireql
 
 import (
+	"cloud.google.com/go/firestore"
 	"fmt"
+	"github.com/pgollangi/fireql/pkg/describe"
+	"github.com/pgollangi/fireql/pkg/explain"
 	selectStmt "github.com/pgollangi/fireql/pkg/select"
+	"github.com/pgollangi/fireql/pkg/show"
+	"github.com/pgollangi/fireql/pkg/support"
 	"github.com/pgollangi/fireql/pkg/util"
 	"github.com/xwb1989/sqlparser"
+	"strings"
 )
 
 // FireQL object is constructed to execute
@@ -23,7 +29,7 @@ type FireQL struct {
 // for more information about how Application Default Credentials are
 // used by Google client libraries
 func New(projectId string, options ...Option) (*FireQL, error) {
-	fql := &FireQL{context: &util.Context{ProjectId: projectId}}
+	fql := &FireQL{context: &util.Context{ProjectId: projectId, Functions: support.NewFunctions()}}
 	for _, opt := range options {
 		if err := opt(fql); err != nil {
 			return nil, err
@@ -40,9 +46,100 @@ func (fql *FireQL) Execute(query string) (*util.QueryResult, error) {
 	switch stmtType {
 	case sqlparser.StmtSelect:
 		return selectStmt.New(fql.context, query).Execute()
-	default:
-		return nil,
-			fmt.Errorf("unsupported sql statement %s. supported querties: SELECT",
-				sqlparser.StmtType(stmtType))
+	case sqlparser.StmtShow:
+		return show.New(fql.context, query).Execute()
+	case sqlparser.StmtOther:
+		switch leadingKeyword(query) {
+		case "describe", "desc":
+			return describe.New(fql.context, query).Execute()
+		case "explain":
+			return explain.New(fql.context, query).Execute()
+		}
+	}
+	return nil,
+		util.NewUnsupportedError(util.CodeUnsupportedStatement, leadingKeyword(query),
+			"unsupported sql statement %s. supported querties: SELECT, SHOW COLLECTIONS, DESCRIBE, EXPLAIN",
+			sqlparser.StmtType(stmtType))
+}
+
+// ExecutePage executes SELECT query returning a page of at most pageSize records,
+// or LIMIT records of the query when pageSize is 0. The first page is returned when
+// pageToken is empty, otherwise the page following the one the token was returned with.
+// QueryResult.NextPageToken is empty when there are no more records.
+// Results sorted on the client side, e.g. ordered by an expression, can't be paged.
+func (fql *FireQL) ExecutePage(query string, pageSize int, pageToken string) (*util.QueryResult, error) {
+	if sqlparser.Preview(query) != sqlparser.StmtSelect {
+		return nil, util.NewUnsupportedError(util.CodeUnsupportedStatement, leadingKeyword(query),
+			"only SELECT queries can be paged")
+	}
+	return selectStmt.New(fql.context, query).ExecutePage(pageSize, pageToken)
+}
+
+// Indexes returns composite index definitions required to serve the queries, in the format of
+// firestore.indexes.json. Queries aren't executed. Statements other than SELECT are skipped,
+// EXPLAIN statements are considered by the SELECT queries they explain.
+func (fql *FireQL) Indexes(queries ...string) (*util.IndexConfig, error) {
+	config := &util.IndexConfig{Indexes: []*util.Index{}, FieldOverrides: []interface{}{}}
+	// queries are compiled with one client, which doesn't connect until a query is run
+	var fireClient *firestore.Client
+	defer func() {
+		if fireClient != nil {
+			fireClient.Close()
+		}
+	}()
+	for _, query := range queries {
+		if leadingKeyword(query) == "explain" {
+			query = strings.TrimSpace(query)[len("explain"):]
+			if leadingKeyword(query) == "analyze" {
+				query = strings.TrimSpace(query)[len("analyze"):]
+			}
+		}
+		if sqlparser.Preview(query) != sqlparser.StmtSelect {
+			continue
+		}
+		if fireClient == nil {
+			var err error
+			if fireClient, err = util.NewFireClient(fql.context); err != nil {
+				return nil, err
+			}
+		}
+		index, err := selectStmt.NewWithClient(fql.context, query, fireClient).RequiredIndex()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", strings.TrimSpace(query), err)
+		}
+		if index != nil && !containsIndex(config.Indexes, index) {
+			config.Indexes = append(config.Indexes, index)
+		}
+	}
+	return config, nil
+}
+
+func containsIndex(indexes []*util.Index, index *util.Index) bool {
+	for _, other := range indexes {
+		if other.Equal(index) {
+			return true
+		}
+	}
+	return false
+}
+
+// leadingKeyword returns the first word of the query in lower case.
+func leadingKeyword(query string) string {
+	words := strings.Fields(query)
+	if len(words) == 0 {
+		return ""
 	}
+	return strings.ToLower(words[0])
+}
+
+// SetDefaultLimit changes the default limit of resulted records
+// for subsequent queries. See OptionDefaultLimit.
+func (fql *FireQL) SetDefaultLimit(limit int) {
+	fql.context.DefaultLimit = limit
+}
+
+// UseDatabase switches subsequent queries to the named Firestore database.
+// Passing an empty name switches back to the "(default)" database.
+func (fql *FireQL) UseDatabase(databaseId string) {
+	fql.context.DatabaseId = databaseId
 }
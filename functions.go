@@ -0,0 +1,50 @@
+package fireql
+
+import (
+	"github.com/pgollangi/fireql/pkg/support"
+)
+
+// Function computes the result of a SQL function from its arguments.
+// Numbers in expressions are float64, values of fields are as read from Firestore.
+type Function = support.Function
+
+// VariadicArgs as maxArgs of RegisterFunction accepts any number of arguments from minArgs.
+const VariadicArgs = support.VariadicParams
+
+// Argument types of function signatures, besides types as reported by DESCRIBE,
+// e.g. "string", "integer", "timestamp" or "array".
+const (
+	AnyType    = support.AnyType
+	NumberType = support.NumberType
+)
+
+// RegisterFunction registers the function callable in queries of all FireQL instances, e.g.
+//
+//	fireql.RegisterFunction("TENANT_OF", tenantOf, 1, 1, "string")
+//
+// Calls with less than minArgs or more than maxArgs arguments are rejected, maxArgs is
+// VariadicArgs for any number of arguments. When argTypes are passed, types of arguments
+// are checked before calling the function, the last type applying to all remaining arguments.
+// NULL arguments match any type. Function names are case-insensitive.
+func RegisterFunction(name string, fn Function, minArgs int, maxArgs int, argTypes ...string) error {
+	return support.GlobalFunctions().Register(name, fn, minArgs, maxArgs, argTypes...)
+}
+
+// RegisterFunction registers the function callable in queries of this FireQL instance only,
+// hiding a global function of the same name. See RegisterFunction.
+func (fql *FireQL) RegisterFunction(name string, fn Function, minArgs int, maxArgs int, argTypes ...string) error {
+	return fql.context.Functions.Register(name, fn, minArgs, maxArgs, argTypes...)
+}
+
+// RegisterVolatileFunction registers the function like RegisterFunction, for functions returning
+// different results on each call, e.g. reading the clock like NOW(). Prepared statements call them
+// on each execution, while other functions of constant arguments in conditions are called once.
+func RegisterVolatileFunction(name string, fn Function, minArgs int, maxArgs int, argTypes ...string) error {
+	return support.GlobalFunctions().RegisterVolatile(name, fn, minArgs, maxArgs, argTypes...)
+}
+
+// RegisterVolatileFunction registers the volatile function callable in queries of this FireQL
+// instance only. See RegisterVolatileFunction.
+func (fql *FireQL) RegisterVolatileFunction(name string, fn Function, minArgs int, maxArgs int, argTypes ...string) error {
+	return fql.context.Functions.RegisterVolatile(name, fn, minArgs, maxArgs, argTypes...)
+}
@@ -20,3 +20,11 @@ func OptionDefaultLimit(limit int) Option {
 		return nil
 	}
 }
+
+// OptionDatabase to query a named Firestore database instead of the "(default)" one.
+func OptionDatabase(databaseId string) Option {
+	return func(fql *FireQL) error {
+		fql.context.DatabaseId = databaseId
+		return nil
+	}
+}
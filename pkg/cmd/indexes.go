@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/pgollangi/fireql"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+)
+
+var indexesCmd = &cobra.Command{
+	Use:   "indexes",
+	Short: "Generate firestore.indexes.json for SQL queries",
+	Long: `Generate composite index definitions in firestore.indexes.json format required to serve the
+SELECT queries of a file, separated by semicolons. Queries aren't executed.`,
+	Example: "fireql indexes -p my-project --from queries.sql > firestore.indexes.json",
+	RunE:    runIndexes,
+}
+
+func init() {
+	indexesCmd.Flags().StringP("from", "f", "", "Required. Path to the file with SQL queries")
+	indexesCmd.Flags().StringP("output", "o", "", "Path to write firestore.indexes.json to. Printed if not set")
+
+	err := indexesCmd.MarkFlagRequired("from")
+	if err != nil {
+		panic(err)
+	}
+	RootCmd.AddCommand(indexesCmd)
+}
+
+func runIndexes(cmd *cobra.Command, args []string) error {
+	from, err := cmd.Flags().GetString("from")
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(from)
+	if err != nil {
+		return errors.New(fmt.Sprintf("from: %s", err))
+	}
+
+	options, err := clientOptions(cmd)
+	if err != nil {
+		return err
+	}
+	projectId, err := cmd.Flags().GetString("project")
+	if err != nil {
+		return err
+	}
+	fsQuery, err := fireql.New(projectId, options...)
+	if err != nil {
+		return err
+	}
+	indexes, err := generateIndexes(fsQuery, splitQueries(string(content)))
+	if err != nil {
+		return err
+	}
+
+	output, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		fmt.Println(indexes)
+		return nil
+	}
+	return os.WriteFile(output, []byte(indexes+"\n"), 0644)
+}
+
+// generateIndexes returns firestore.indexes.json content required to serve the queries.
+func generateIndexes(fsQuery *fireql.FireQL, queries []string) (string, error) {
+	config, err := fsQuery.Indexes(queries...)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// splitQueries splits SQL text into queries separated by semicolons,
+// skipping quoted semicolons, comments and empty queries.
+func splitQueries(text string) []string {
+	var queries []string
+	var query strings.Builder
+	addQuery := func() {
+		if q := strings.TrimSpace(query.String()); q != "" {
+			queries = append(queries, q)
+		}
+		query.Reset()
+	}
+
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote != '`' && i+1 < len(text) {
+				query.WriteByte(c)
+				i++
+				c = text[i]
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == ';':
+			addQuery()
+			continue
+		case strings.HasPrefix(text[i:], "--") || c == '#':
+			// Skip the comment until the end of line
+			for i < len(text) && text[i] != '\n' {
+				i++
+			}
+			query.WriteByte('\n')
+			continue
+		case strings.HasPrefix(text[i:], "/*"):
+			// Skip the comment until its end, or the end of text if it isn't closed
+			end := strings.Index(text[i+2:], "*/")
+			if end < 0 {
+				i = len(text)
+			} else {
+				i += end + 3
+			}
+			query.WriteByte(' ')
+			continue
+		}
+		query.WriteByte(c)
+	}
+	addQuery()
+	return queries
+}
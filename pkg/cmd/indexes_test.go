@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/pgollangi/fireql"
+	"strings"
+	"testing"
+)
+
+func TestSplitQueries(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected []string
+	}{
+		{text: "select * from users; select * from orders;", expected: []string{"select * from users", "select * from orders"}},
+		{text: " ; \n;", expected: nil},
+		{text: "select * from users where name = 'a;b'", expected: []string{"select * from users where name = 'a;b'"}},
+		{text: `select * from users where name = "a\";b"`, expected: []string{`select * from users where name = "a\";b"`}},
+		{text: "select `a;b` from users; select 1 from t", expected: []string{"select `a;b` from users", "select 1 from t"}},
+		{text: "select * from users -- all; users\n; # none;\nselect * from orders", expected: []string{"select * from users", "select * from orders"}},
+		{text: "select * /* all; fields */ from users;/* ; */", expected: []string{"select *   from users"}},
+		{text: "select * from users where name = '/* a; */'", expected: []string{"select * from users where name = '/* a; */'"}},
+		{text: "select * from users /* unclosed; comment", expected: []string{"select * from users"}},
+	}
+	for _, tt := range tests {
+		if diff := cmp.Diff(tt.expected, splitQueries(tt.text)); diff != "" {
+			t.Errorf("%q: mismatch (-expected +actual):\n%s", tt.text, diff)
+		}
+	}
+}
+
+func TestGenerateIndexes(t *testing.T) {
+	// queries are compiled without connecting to Firestore
+	t.Setenv("FIRESTORE_EMULATOR_HOST", "localhost:1")
+	fsQuery, err := fireql.New("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	queries := splitQueries(`
+		select * from users where city = 'Paris' order by age; -- needs an index
+		/* the same index; listed once */ select name from users where city = 'Rome' order by age;
+		select * from users where age > 20;
+		show collections`)
+	indexes, err := generateIndexes(fsQuery, queries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{`"collectionGroup": "users"`, `"fieldPath": "city"`, `"fieldPath": "age"`} {
+		if strings.Count(indexes, expected) != 1 {
+			t.Errorf("expected %s once in:\n%s", expected, indexes)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type metaCommand struct {
+	usage       string
+	description string
+	run         func(args []string) error
+}
+
+var metaCommands map[string]*metaCommand
+
+func init() {
+	listCollections := &metaCommand{
+		usage:       `\l, \dt`,
+		description: "list root collections",
+		run:         runListCollections,
+	}
+	metaCommands = map[string]*metaCommand{
+		`\l`:  listCollections,
+		`\dt`: listCollections,
+		`\d`: {
+			usage:       `\d COLLECTION`,
+			description: "describe inferred schema of a collection",
+			run:         runDescribe,
+		},
+		`\indexes`: {
+			usage:       `\indexes`,
+			description: "print firestore.indexes.json required by queries of the session",
+			run:         runIndexesMeta,
+		},
+		`\timing`: {
+			usage:       `\timing [on|off]`,
+			description: "toggle display of query execution time",
+			run:         runTiming,
+		},
+		`\x`: {
+			usage:       `\x [on|off]`,
+			description: "toggle expanded (vertical) output",
+			run:         runExpanded,
+		},
+		`\limit`: {
+			usage:       `\limit N`,
+			description: "change default limit of SELECTed results. 0 for unlimited",
+			run:         runLimit,
+		},
+		`\use`: {
+			usage:       `\use DATABASE`,
+			description: `switch to another Firestore database. "(default)" for the default one`,
+			run:         runUse,
+		},
+		`\?`: {
+			usage:       `\?`,
+			description: "show help on meta-commands",
+			run:         runHelp,
+		},
+		`\q`: {
+			usage:       `\q`,
+			description: "quit fireql",
+			run: func(args []string) error {
+				os.Exit(0)
+				return nil
+			},
+		},
+	}
+}
+
+func isMetaCommand(in string) bool {
+	return strings.HasPrefix(strings.TrimSpace(in), `\`)
+}
+
+func executeMetaCommand(in string) error {
+	args := strings.Fields(in)
+	command := metaCommands[args[0]]
+	if command == nil {
+		return fmt.Errorf(`invalid command %s. Try \? for help`, args[0])
+	}
+	return command.run(args[1:])
+}
+
+func runListCollections(args []string) error {
+	result, err := ctx.fsQuery.Execute("SHOW COLLECTIONS")
+	if err != nil {
+		return err
+	}
+	printResult(result)
+	return nil
+}
+
+func runDescribe(args []string) error {
+	if len(args) != 1 {
+		return errors.New(`usage: \d COLLECTION`)
+	}
+	result, err := ctx.fsQuery.Execute(fmt.Sprintf("DESCRIBE %s", args[0]))
+	if err != nil {
+		return err
+	}
+	printResult(result)
+	return nil
+}
+
+func runIndexesMeta(args []string) error {
+	indexes, err := generateIndexes(ctx.fsQuery, ctx.history)
+	if err != nil {
+		return err
+	}
+	fmt.Println(indexes)
+	return nil
+}
+
+func runTiming(args []string) error {
+	timing, err := toggleArg(ctx.timing, args)
+	if err != nil {
+		return err
+	}
+	ctx.timing = timing
+	fmt.Printf("Timing is %s.\n", onOff(timing))
+	return nil
+}
+
+func runExpanded(args []string) error {
+	expanded, err := toggleArg(ctx.expanded, args)
+	if err != nil {
+		return err
+	}
+	ctx.expanded = expanded
+	fmt.Printf("Expanded display is %s.\n", onOff(expanded))
+	return nil
+}
+
+func runLimit(args []string) error {
+	if len(args) != 1 {
+		return errors.New(`usage: \limit N`)
+	}
+	limit, err := strconv.Atoi(args[0])
+	if err != nil || limit < 0 {
+		return fmt.Errorf("invalid limit %s, expected a non-negative number", args[0])
+	}
+	ctx.fsQuery.SetDefaultLimit(limit)
+	fmt.Printf("Default limit is %d.\n", limit)
+	return nil
+}
+
+func runUse(args []string) error {
+	if len(args) != 1 {
+		return errors.New(`usage: \use DATABASE`)
+	}
+	database := args[0]
+	if database == "(default)" {
+		database = ""
+	}
+	ctx.fsQuery.UseDatabase(database)
+	fmt.Printf("Using database %s.\n", args[0])
+	return nil
+}
+
+func runHelp(args []string) error {
+	var names []string
+	seen := map[*metaCommand]bool{}
+	for name, command := range metaCommands {
+		if !seen[command] {
+			seen[command] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		command := metaCommands[name]
+		fmt.Printf("  %-20s %s\n", command.usage, command.description)
+	}
+	return nil
+}
+
+func toggleArg(current bool, args []string) (bool, error) {
+	if len(args) == 0 {
+		return !current, nil
+	}
+	switch strings.ToLower(args[0]) {
+	case "on":
+		return true, nil
+	case "off":
+		return false, nil
+	}
+	return current, fmt.Errorf(`unrecognized value "%s", expected on or off`, args[0])
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
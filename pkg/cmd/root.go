@@ -10,6 +10,8 @@ import (
 	"github.com/pgollangi/fireql/pkg/util"
 	"github.com/spf13/cobra"
 	"os"
+	"strings"
+	"time"
 )
 
 // Version is the version for fireql
@@ -29,11 +31,12 @@ var RootCmd = &cobra.Command{
 }
 
 func init() {
-	RootCmd.Flags().StringP("project", "p", "", "Required. Id of the GCP project")
-	RootCmd.Flags().StringP("service-account", "s", "", "Path to service account file to authenticate with Firestore")
+	RootCmd.PersistentFlags().StringP("project", "p", "", "Required. Id of the GCP project")
+	RootCmd.PersistentFlags().StringP("service-account", "s", "", "Path to service account file to authenticate with Firestore")
+	RootCmd.PersistentFlags().StringP("database", "d", "", "Id of the Firestore database to query. Uses \"(default)\" database if not set")
 	RootCmd.Flags().IntP("limit", "l", 100, "Default limit to apply on SELECTed results. Set `0` to result unlimited.")
 
-	err := RootCmd.MarkFlagRequired("project")
+	err := RootCmd.MarkPersistentFlagRequired("project")
 	if err != nil {
 		panic(err)
 	}
@@ -49,34 +52,22 @@ func Execute() {
 }
 
 type Context struct {
-	fsQuery *fireql.FireQL
+	fsQuery  *fireql.FireQL
+	timing   bool
+	expanded bool
+	// queries executed in the session
+	history []string
 }
 
 var ctx *Context
 
 func runCommand(cmd *cobra.Command, args []string) {
-	projectId, err := cmd.Flags().GetString("project")
+	options, err := clientOptions(cmd)
 	if err != nil {
 		printError(err)
 		return
 	}
 
-	var options []fireql.Option
-
-	serviceAccountFile, err := cmd.Flags().GetString("service-account")
-	if err != nil {
-		printError(errors.New(fmt.Sprintf("service-account: %s", err)))
-		return
-	}
-	if serviceAccountFile != "" {
-		serviceAccount, err := os.ReadFile(serviceAccountFile)
-		if err != nil {
-			printError(errors.New(fmt.Sprintf("service-account: %s", err)))
-			return
-		}
-		options = append(options, fireql.OptionServiceAccount(string(serviceAccount)))
-	}
-
 	defaultLimit, err := cmd.Flags().GetInt("limit")
 	if err != nil {
 		printError(errors.New(fmt.Sprintf("limit: %s", err)))
@@ -86,6 +77,11 @@ func runCommand(cmd *cobra.Command, args []string) {
 		options = append(options, fireql.OptionDefaultLimit(defaultLimit))
 	}
 
+	projectId, err := cmd.Flags().GetString("project")
+	if err != nil {
+		printError(err)
+		return
+	}
 	fsQuery, err := fireql.New(projectId, options...)
 	if err != nil {
 		printError(err)
@@ -94,10 +90,36 @@ func runCommand(cmd *cobra.Command, args []string) {
 
 	ctx = &Context{fsQuery: fsQuery}
 
-	fmt.Println("Welcome! Use SQL to query Firestore.\nUse Ctrl+D, type \"exit\" to exit.\nVisit github.com/pgollangi/FireQL for more details.")
+	fmt.Println("Welcome! Use SQL to query Firestore.\nUse Ctrl+D, type \"exit\" to exit. Type \"\\?\" for help on meta-commands.\nVisit github.com/pgollangi/FireQL for more details.")
 	initPrompt()
 }
 
+// clientOptions returns options to connect to Firestore passed as flags of the command.
+func clientOptions(cmd *cobra.Command) ([]fireql.Option, error) {
+	var options []fireql.Option
+
+	serviceAccountFile, err := cmd.Flags().GetString("service-account")
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("service-account: %s", err))
+	}
+	if serviceAccountFile != "" {
+		serviceAccount, err := os.ReadFile(serviceAccountFile)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("service-account: %s", err))
+		}
+		options = append(options, fireql.OptionServiceAccount(string(serviceAccount)))
+	}
+
+	database, err := cmd.Flags().GetString("database")
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("database: %s", err))
+	}
+	if database != "" {
+		options = append(options, fireql.OptionDatabase(database))
+	}
+	return options, nil
+}
+
 func initPrompt() {
 	p := prompt.New(
 		executor,
@@ -110,31 +132,62 @@ func initPrompt() {
 }
 
 func printResult(result *util.QueryResult) {
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader(result.Columns)
+	if ctx.expanded {
+		printExpandedResult(result)
+	} else {
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader(result.Columns)
 
-	for _, row := range result.Records {
-		tRow := make([]string, len(result.Columns))
-		for idx, val := range row {
-			switch cellVal := val.(type) {
-			case map[string]interface{}:
-				jsonVal, err := json.Marshal(cellVal)
-				if err == nil {
-					val = string(jsonVal)
-				} else {
-					val = errors.New("error converting map to JSON")
-				}
+		for _, row := range result.Records {
+			tRow := make([]string, len(result.Columns))
+			for idx, val := range row {
+				tRow[idx] = formatValue(val)
 			}
-			tRow[idx] = fmt.Sprintf("%v", val)
+			table.Append(tRow)
 		}
-		table.Append(tRow)
+		table.Render()
 	}
-	table.Render()
 	fmt.Printf("(%d rows)\n", len(result.Records))
+	if result.NextPageToken != "" {
+		fmt.Printf("Next page: AFTER '%s'\n", result.NextPageToken)
+	}
+}
+
+// printExpandedResult prints each record vertically, one "column | value" line per column.
+func printExpandedResult(result *util.QueryResult) {
+	width := 0
+	for _, column := range result.Columns {
+		if len(column) > width {
+			width = len(column)
+		}
+	}
+	for rIdx, row := range result.Records {
+		fmt.Printf("-[ RECORD %d ]%s\n", rIdx+1, strings.Repeat("-", width))
+		for idx, val := range row {
+			fmt.Printf("%-*s | %s\n", width, result.Columns[idx], formatValue(val))
+		}
+	}
+}
+
+func formatValue(val interface{}) string {
+	switch cellVal := val.(type) {
+	case map[string]interface{}:
+		jsonVal, err := json.Marshal(cellVal)
+		if err == nil {
+			val = string(jsonVal)
+		} else {
+			val = errors.New("error converting map to JSON")
+		}
+	}
+	return fmt.Sprintf("%v", val)
 }
 
 func printError(err error) {
 	fmt.Printf("error: %s \n", err.Error())
+	var firestoreErr *util.FirestoreError
+	if errors.As(err, &firestoreErr) && firestoreErr.MissingIndex != nil {
+		fmt.Printf("hint: create composite index %s\n", firestoreErr.MissingIndex)
+	}
 }
 
 func executor(q string) {
@@ -142,12 +195,29 @@ func executor(q string) {
 		os.Exit(0)
 		return
 	}
+	if isMetaCommand(q) {
+		if err := executeMetaCommand(q); err != nil {
+			printError(err)
+		}
+		return
+	}
+	start := time.Now()
 	result, err := ctx.fsQuery.Execute(q)
+	elapsed := time.Since(start)
+	var parseErr *util.ParseError
+	var unsupportedErr *util.UnsupportedError
+	if !errors.As(err, &parseErr) && !errors.As(err, &unsupportedErr) {
+		// Queries failed for missing indexes are kept to generate indexes for
+		ctx.history = append(ctx.history, q)
+	}
 	if err != nil {
 		printError(err)
 	} else {
 		printResult(result)
 	}
+	if ctx.timing {
+		fmt.Printf("Time: %.3f ms\n", float64(elapsed.Microseconds())/1000)
+	}
 }
 func completer(d prompt.Document) []prompt.Suggest {
 	var s []prompt.Suggest
@@ -0,0 +1,193 @@
+package describe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/pgollangi/fireql/pkg/support"
+	"github.com/pgollangi/fireql/pkg/util"
+	"google.golang.org/api/iterator"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultSampleSize is the number of documents sampled to infer
+// the schema when DESCRIBE doesn't specify a LIMIT.
+const DefaultSampleSize = 100
+
+// maxExamples is the number of distinct example values reported per field.
+const maxExamples = 3
+
+// maxExampleLength truncates long example values.
+const maxExampleLength = 40
+
+var describeRegex = regexp.MustCompile("(?is)^\\s*(?:describe|desc)\\s+(`[^`]+`|\\[[^\\]]+\\]|[^\\s;]+)(?:\\s+limit\\s+(\\d+))?\\s*;?\\s*$")
+
+var simpleFieldRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z_0-9]*$`)
+
+type DescribeStatement struct {
+	context  *util.Context
+	rawQuery string
+}
+
+func New(context *util.Context, rawQuery string) *DescribeStatement {
+	return &DescribeStatement{
+		context,
+		rawQuery,
+	}
+}
+
+type fieldStats struct {
+	path     string
+	count    int
+	types    map[string]int
+	examples []string
+}
+
+func (desc *DescribeStatement) Execute() (*util.QueryResult, error) {
+	matches := describeRegex.FindStringSubmatch(desc.rawQuery)
+	if matches == nil {
+		return nil, util.NewParseError(util.CodeSyntaxError, desc.rawQuery, "invalid DESCRIBE statement. expected: DESCRIBE collection [LIMIT n]")
+	}
+	collection := matches[1]
+	sampleSize := DefaultSampleSize
+	if matches[2] != "" {
+		size, err := strconv.Atoi(matches[2])
+		if err != nil || size <= 0 {
+			return nil, util.NewParseError(util.CodeInvalidArgument, matches[2], "invalid DESCRIBE sample size %s", matches[2])
+		}
+		sampleSize = size
+	}
+
+	fireClient, err := util.NewFireClient(desc.context)
+	if err != nil {
+		return nil, err
+	}
+	defer fireClient.Close()
+
+	source, err := util.ResolveCollection(fireClient, collection)
+	if err != nil {
+		return nil, err
+	}
+	docs := source.Query.Limit(sampleSize).Documents(context.Background())
+	defer docs.Stop()
+
+	stats := map[string]*fieldStats{}
+	total := 0
+	for {
+		document, err := docs.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		} else if err != nil {
+			return nil, util.NewFirestoreError(err)
+		}
+		total++
+		collectStats(stats, "", document.Data())
+	}
+
+	var fields []*fieldStats
+	for _, field := range stats {
+		fields = append(fields, field)
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].path < fields[j].path
+	})
+
+	records := [][]interface{}{}
+	for _, field := range fields {
+		records = append(records, []interface{}{
+			field.path,
+			formatTypes(field),
+			fmt.Sprintf("%.1f%%", percent(field.count, total)),
+			strings.Join(field.examples, ", "),
+		})
+	}
+	return &util.QueryResult{
+		Columns: []string{"field", "types", "presence", "examples"},
+		Records: records,
+	}, nil
+}
+
+// collectStats records type and example of every field in data,
+// descending into nested maps using dotted field paths.
+func collectStats(stats map[string]*fieldStats, prefix string, data map[string]interface{}) {
+	for key, val := range data {
+		path := fieldPath(prefix, key)
+		field := stats[path]
+		if field == nil {
+			field = &fieldStats{path: path, types: map[string]int{}}
+			stats[path] = field
+		}
+		field.count++
+		field.types[support.FirestoreType(val)]++
+		if example := formatExample(val); len(field.examples) < maxExamples && !contains(field.examples, example) {
+			field.examples = append(field.examples, example)
+		}
+		if nested, ok := val.(map[string]interface{}); ok {
+			collectStats(stats, path, nested)
+		}
+	}
+}
+
+func fieldPath(prefix string, key string) string {
+	if !simpleFieldRegex.MatchString(key) {
+		key = "`" + strings.ReplaceAll(key, "`", "\\`") + "`"
+	}
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// formatTypes formats observed types of the field ordered by frequency,
+// e.g. "string (90.0%), null (10.0%)".
+func formatTypes(field *fieldStats) string {
+	var types []string
+	for t := range field.types {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if field.types[types[i]] != field.types[types[j]] {
+			return field.types[types[i]] > field.types[types[j]]
+		}
+		return types[i] < types[j]
+	})
+	for idx, t := range types {
+		types[idx] = fmt.Sprintf("%s (%.1f%%)", t, percent(field.types[t], field.count))
+	}
+	return strings.Join(types, ", ")
+}
+
+func formatExample(val interface{}) string {
+	var example string
+	switch val := val.(type) {
+	case map[string]interface{}:
+		example = "{...}"
+	case string:
+		example = strconv.Quote(val)
+	default:
+		example = fmt.Sprintf("%v", val)
+	}
+	if len(example) > maxExampleLength {
+		example = example[:maxExampleLength] + "..."
+	}
+	return example
+}
+
+func percent(count int, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(count) * 100 / float64(total)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,38 @@
+package describe
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"testing"
+)
+
+func TestCollectStats(t *testing.T) {
+	stats := map[string]*fieldStats{}
+	collectStats(stats, "", map[string]interface{}{
+		"name":    "Terry",
+		"address": map[string]interface{}{"city": "Washington", "zip-code": int64(20001)},
+	})
+	collectStats(stats, "", map[string]interface{}{
+		"name":    nil,
+		"address": map[string]interface{}{"city": "Louisville"},
+	})
+
+	expected := map[string]string{
+		"name":               "null (50.0%), string (50.0%)",
+		"address":            "map (100.0%)",
+		"address.city":       "string (100.0%)",
+		"address.`zip-code`": "integer (100.0%)",
+	}
+	actual := map[string]string{}
+	for path, field := range stats {
+		actual[path] = formatTypes(field)
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Errorf("formatTypes mismatch (-expected +actual):\n%s", diff)
+	}
+	if stats["address.city"].count != 2 || stats["address.`zip-code`"].count != 1 {
+		t.Errorf("unexpected field counts: %d, %d", stats["address.city"].count, stats["address.`zip-code`"].count)
+	}
+	if diff := cmp.Diff([]string{`"Washington"`, `"Louisville"`}, stats["address.city"].examples); diff != "" {
+		t.Errorf("examples mismatch (-expected +actual):\n%s", diff)
+	}
+}
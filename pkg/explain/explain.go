@@ -0,0 +1,40 @@
+package explain
+
+import (
+	selectStmt "github.com/pgollangi/fireql/pkg/select"
+	"github.com/pgollangi/fireql/pkg/util"
+	"github.com/xwb1989/sqlparser"
+	"regexp"
+	"strings"
+)
+
+var explainRegex = regexp.MustCompile("(?is)^\\s*explain\\s+(analyze\\s+)?(.*)$")
+
+type ExplainStatement struct {
+	context  *util.Context
+	rawQuery string
+}
+
+func New(context *util.Context, rawQuery string) *ExplainStatement {
+	return &ExplainStatement{
+		context,
+		rawQuery,
+	}
+}
+
+// Execute describes the Firestore query the explained SELECT query translates to,
+// without running it. EXPLAIN ANALYZE runs the query and reports its cost as well.
+func (explain *ExplainStatement) Execute() (*util.QueryResult, error) {
+	matches := explainRegex.FindStringSubmatch(explain.rawQuery)
+	if matches == nil {
+		return nil, util.NewParseError(util.CodeSyntaxError, explain.rawQuery, "invalid EXPLAIN statement. expected: EXPLAIN [ANALYZE] SELECT ...")
+	}
+	query := strings.TrimSpace(matches[2])
+	if sqlparser.Preview(query) != sqlparser.StmtSelect {
+		return nil, util.NewUnsupportedError(util.CodeUnsupportedStatement, query, "only SELECT queries can be explained")
+	}
+	if matches[1] != "" {
+		return selectStmt.New(explain.context, query).Analyze()
+	}
+	return selectStmt.New(explain.context, query).Explain()
+}
@@ -0,0 +1,180 @@
+package _select
+
+import (
+	"cloud.google.com/go/firestore"
+	"errors"
+	"fmt"
+	"github.com/pgollangi/fireql/pkg/util"
+	"google.golang.org/api/iterator"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// queryStats counts the cost of running the query. Index entries scanned are reported by
+// Firestore query explain, the rest is counted by FireQL.
+type queryStats struct {
+	documentsRead int
+	// index entries scanned by queries, and the number of queries run and
+	// of those Firestore reported it for
+	indexEntries     int64
+	queries          int
+	explainedQueries int
+	// storage size of documents returned by Firestore
+	bytesReturned int
+	// time spent waiting for Firestore
+	fetchTime time.Duration
+}
+
+// explainOptions asks Firestore for execution stats of queries run by EXPLAIN ANALYZE.
+var explainOptions = firestore.ExplainOptions{Analyze: true}
+
+// addQuery adds the index entries scanned by the query, read to the end, to the stats.
+func (stats *queryStats) addQuery(docs *firestore.DocumentIterator) {
+	stats.queries++
+	metrics, err := docs.ExplainMetrics()
+	if err != nil || metrics == nil {
+		return
+	}
+	if entries, ok := indexEntriesScanned(metrics.ExecutionStats); ok {
+		stats.indexEntries += entries
+		stats.explainedQueries++
+	}
+}
+
+// indexEntriesScanned returns index entries scanned from debug stats of the query execution.
+func indexEntriesScanned(executionStats *firestore.ExecutionStats) (int64, bool) {
+	if executionStats == nil || executionStats.DebugStats == nil {
+		return 0, false
+	}
+	// the client library documents the key as indexes_entries_scanned
+	for _, key := range []string{"index_entries_scanned", "indexes_entries_scanned"} {
+		switch val := (*executionStats.DebugStats)[key].(type) {
+		case string:
+			if entries, err := strconv.ParseInt(val, 10, 64); err == nil {
+				return entries, true
+			}
+		case float64:
+			return int64(val), true
+		}
+	}
+	return 0, false
+}
+
+// statsIterator counts documents read by the iterator.
+type statsIterator struct {
+	docs  documentIterator
+	stats *queryStats
+	// documents looked up by reference are counted when read
+	lookup bool
+	done   bool
+}
+
+func (it *statsIterator) Next() (*firestore.DocumentSnapshot, error) {
+	start := time.Now()
+	document, err := it.docs.Next()
+	it.stats.fetchTime += time.Since(start)
+	if err == nil {
+		if !it.lookup {
+			it.stats.documentsRead++
+		}
+		it.stats.bytesReturned += documentSize(document)
+	} else if queryDocs, ok := it.docs.(*firestore.DocumentIterator); ok && errors.Is(err, iterator.Done) && !it.done {
+		// explain metrics are available once the query is read to the end
+		it.done = true
+		it.stats.addQuery(queryDocs)
+	}
+	return document, err
+}
+
+// formatIndexEntries formats index entries scanned by the queries run, unknown when Firestore
+// didn't report them, e.g. for queries not read to the end.
+func (stats *queryStats) formatIndexEntries() string {
+	switch {
+	case stats.explainedQueries == stats.queries:
+		return fmt.Sprint(stats.indexEntries)
+	case stats.explainedQueries == 0:
+		return "n/a (not reported by Firestore)"
+	}
+	return fmt.Sprintf("%d (reported for %d of %d queries)", stats.indexEntries, stats.explainedQueries, stats.queries)
+}
+
+// Analyze runs the query and describes it like Explain, followed by the cost of running it.
+// Records of the query are discarded.
+func (sel *SelectStatement) Analyze() (*util.QueryResult, error) {
+	plan, err := sel.compile()
+	defer sel.closeClient()
+	if err != nil {
+		return nil, err
+	}
+	sel.stats = &queryStats{}
+	start := time.Now()
+	result, err := sel.run(plan, nil)
+	if err != nil {
+		return nil, err
+	}
+	elapsed := time.Since(start)
+
+	records := sel.explainPlan(plan)
+	addStep := func(step string, detail string) {
+		records = append(records, []interface{}{step, detail})
+	}
+	documentsRead := fmt.Sprint(sel.stats.documentsRead)
+	if sel.stats.documentsRead == 0 && plan.docRefs == nil {
+		documentsRead += " (billed as 1)"
+	}
+	addStep("documents read", documentsRead)
+	addStep("index entries scanned", sel.stats.formatIndexEntries())
+	addStep("bytes returned", fmt.Sprintf("%d (estimated)", sel.stats.bytesReturned))
+	addStep("rows returned", fmt.Sprint(len(result.Records)))
+	addStep("elapsed time", formatDuration(elapsed))
+	addStep("client processing time", formatDuration(elapsed-sel.stats.fetchTime))
+	return &util.QueryResult{Columns: []string{"step", "detail"}, Records: records}, nil
+}
+
+func formatDuration(duration time.Duration) string {
+	return duration.Round(time.Microsecond).String()
+}
+
+// documentSize calculates storage size of the document.
+// See https://firebase.google.com/docs/firestore/storage-size
+func documentSize(document *firestore.DocumentSnapshot) int {
+	return documentNameSize(document.Ref) + valueSize(document.Data()) + 32
+}
+
+func documentNameSize(ref *firestore.DocumentRef) int {
+	size := 16
+	for _, segment := range strings.Split(documentPath(ref), "/") {
+		size += len(segment) + 1
+	}
+	return size
+}
+
+func valueSize(val interface{}) int {
+	switch val := val.(type) {
+	case nil, bool:
+		return 1
+	case int64, float64, time.Time:
+		return 8
+	case string:
+		return len(val) + 1
+	case []byte:
+		return len(val)
+	case *firestore.DocumentRef:
+		return documentNameSize(val)
+	case []interface{}:
+		size := 0
+		for _, element := range val {
+			size += valueSize(element)
+		}
+		return size
+	case map[string]interface{}:
+		size := 0
+		for key, entry := range val {
+			size += len(key) + 1 + valueSize(entry)
+		}
+		return size
+	}
+	// Geo points
+	return 16
+}
@@ -0,0 +1,181 @@
+package _select
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/pgollangi/fireql/pkg/support"
+	"github.com/pgollangi/fireql/pkg/util"
+	"github.com/xwb1989/sqlparser"
+	"google.golang.org/api/iterator"
+	"time"
+)
+
+// derefBatchSize is the number of rows read ahead to fetch the documents they dereference at once.
+const derefBatchSize = 100
+
+// compileDeref compiles DEREF(author, 'name'), which author->name and DEREF(author).name are
+// rewritten into, reading the field of the document the reference points to. Without a field
+// path, DEREF(author) is all fields of the document. Missing documents and fields are NULL.
+func (c *exprCompiler) compileDeref(expr *sqlparser.FuncExpr) (evaluator, error) {
+	if len(expr.Exprs) < 1 || len(expr.Exprs) > 2 {
+		return nil, util.NewParseError(util.CodeInvalidArgument, sqlparser.String(expr), "DEREF expects a reference and an optional field path")
+	}
+	var args []sqlparser.Expr
+	for _, arg := range expr.Exprs {
+		aliasedArg, ok := arg.(*sqlparser.AliasedExpr)
+		if !ok {
+			return nil, util.NewParseError(util.CodeInvalidArgument, sqlparser.String(arg), "unsupported argument %s to DEREF", sqlparser.String(arg))
+		}
+		args = append(args, aliasedArg.Expr)
+	}
+	var path firestore.FieldPath
+	if len(args) == 2 {
+		pathVal, ok := args[1].(*sqlparser.SQLVal)
+		if !ok || pathVal.Type != sqlparser.StrVal {
+			return nil, util.NewParseError(util.CodeInvalidArgument, sqlparser.String(args[1]), "DEREF expects a field path, got %s", sqlparser.String(args[1]))
+		}
+		var err error
+		if path, err = parseFieldPath(string(pathVal.Val)); err != nil {
+			return nil, err
+		}
+	}
+	ref, err := c.compile(args[0])
+	if err != nil {
+		return nil, err
+	}
+	c.derefs = append(c.derefs, ref)
+	return func(document *firestore.DocumentSnapshot, data map[string]interface{}, env *evalEnv) (interface{}, error) {
+		val, err := ref(document, data, env)
+		if err != nil || val == nil {
+			return nil, err
+		}
+		ref, ok := val.(*firestore.DocumentRef)
+		if !ok {
+			return nil, fmt.Errorf("can't dereference %s value %v", support.FirestoreType(val), val)
+		}
+		if isDocumentID(path) {
+			return ref.ID, nil
+		}
+		fields, err := env.refs.get(ref)
+		if err != nil || fields == nil {
+			return nil, err
+		}
+		if path == nil {
+			return fields, nil
+		}
+		fieldVal, _ := lookupField(nil, fields, path)
+		return fieldVal, nil
+	}, nil
+}
+
+// refCache caches documents dereferenced by a query run, so that each is read once.
+type refCache struct {
+	sel *SelectStatement
+	// fields of documents by their paths, nil for missing documents
+	docs map[string]map[string]interface{}
+}
+
+func newRefCache(sel *SelectStatement) *refCache {
+	return &refCache{sel: sel, docs: map[string]map[string]interface{}{}}
+}
+
+// get returns fields of the referenced document, reading it unless it's cached.
+func (cache *refCache) get(ref *firestore.DocumentRef) (map[string]interface{}, error) {
+	if fields, ok := cache.docs[ref.Path]; ok {
+		return fields, nil
+	}
+	if err := cache.fetch([]*firestore.DocumentRef{ref}); err != nil {
+		return nil, err
+	}
+	return cache.docs[ref.Path], nil
+}
+
+// fetch reads the referenced documents that aren't cached, with a single GetAll call.
+func (cache *refCache) fetch(refs []*firestore.DocumentRef) error {
+	var missing []*firestore.DocumentRef
+	seen := map[string]bool{}
+	for _, ref := range refs {
+		if _, ok := cache.docs[ref.Path]; !ok && !seen[ref.Path] {
+			seen[ref.Path] = true
+			missing = append(missing, ref)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	start := time.Now()
+	snapshots, err := cache.sel.fireClient.GetAll(context.Background(), missing)
+	if err != nil {
+		return err
+	}
+	if stats := cache.sel.stats; stats != nil {
+		stats.fetchTime += time.Since(start)
+		// Looking up missing documents is a read as well
+		stats.documentsRead += len(snapshots)
+	}
+	for _, snapshot := range snapshots {
+		var fields map[string]interface{}
+		if snapshot.Exists() {
+			fields = snapshot.Data()
+		}
+		cache.docs[snapshot.Ref.Path] = fields
+	}
+	return nil
+}
+
+// derefIterator reads rows ahead in batches, and fetches the documents they dereference
+// with one GetAll call per batch and level of nesting, instead of one read per row.
+type derefIterator struct {
+	rows      rowIterator
+	derefs    []evaluator
+	env       *evalEnv
+	batchSize int
+	batch     []joinedRow
+	done      bool
+}
+
+func (it *derefIterator) Next() (*firestore.DocumentSnapshot, map[string]interface{}, error) {
+	if len(it.batch) == 0 && !it.done {
+		if err := it.readBatch(); err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(it.batch) == 0 {
+		return nil, nil, iterator.Done
+	}
+	row := it.batch[0]
+	it.batch = it.batch[1:]
+	return row.document, row.data, nil
+}
+
+// readBatch reads the next batch of rows and fetches the documents they dereference.
+func (it *derefIterator) readBatch() error {
+	for len(it.batch) < it.batchSize {
+		document, data, err := it.rows.Next()
+		if errors.Is(err, iterator.Done) {
+			it.done = true
+			break
+		} else if err != nil {
+			return err
+		}
+		it.batch = append(it.batch, joinedRow{document: document, data: data})
+	}
+	// references read from dereferenced documents, e.g. post->author->name,
+	// are evaluated once the documents they're read from are fetched
+	for _, deref := range it.derefs {
+		var refs []*firestore.DocumentRef
+		for _, row := range it.batch {
+			if val, err := deref(row.document, row.data, it.env); err == nil {
+				if ref, ok := val.(*firestore.DocumentRef); ok {
+					refs = append(refs, ref)
+				}
+			}
+		}
+		if err := it.env.refs.fetch(refs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package _select
+
+import (
+	"cloud.google.com/go/firestore"
+	"errors"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pgollangi/fireql/pkg/util"
+	"github.com/xwb1989/sqlparser"
+	"testing"
+)
+
+func TestDeref(t *testing.T) {
+	author := &firestore.DocumentRef{ID: "1", Path: "projects/p/databases/(default)/documents/users/1"}
+	manager := &firestore.DocumentRef{ID: "2", Path: "projects/p/databases/(default)/documents/users/2"}
+	deleted := &firestore.DocumentRef{ID: "3", Path: "projects/p/databases/(default)/documents/users/3"}
+	env := &evalEnv{refs: &refCache{docs: map[string]map[string]interface{}{
+		author.Path:  {"name": "Ann", "address": map[string]interface{}{"city": "Paris"}, "manager": manager},
+		manager.Path: {"name": "Bob"},
+		deleted.Path: nil,
+	}}}
+	data := map[string]interface{}{"author": author, "editor": deleted, "title": "Go", "reviewer": nil}
+	document := &firestore.DocumentSnapshot{Ref: &firestore.DocumentRef{ID: "p1"}}
+
+	tests := []struct {
+		expr     string
+		expected interface{}
+		derefs   int
+	}{
+		{expr: "author->name", expected: "Ann", derefs: 1},
+		{expr: "DEREF(author).address.city", expected: "Paris", derefs: 1},
+		{expr: "author->manager->name", expected: "Bob", derefs: 2},
+		{expr: "author->__name__", expected: "1", derefs: 1},
+		{expr: "author->age", expected: nil, derefs: 1},
+		{expr: "editor->name", expected: nil, derefs: 1},
+		{expr: "reviewer->name", expected: nil, derefs: 1},
+		{expr: "DEREF(author->manager)", expected: map[string]interface{}{"name": "Bob"}, derefs: 2},
+	}
+	for _, tt := range tests {
+		stmt, err := sqlparser.Parse(rewriteQuery("select " + tt.expr + " from posts"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		compiler := &exprCompiler{}
+		eval, err := compiler.compile(stmt.(*sqlparser.Select).SelectExprs[0].(*sqlparser.AliasedExpr).Expr)
+		if err != nil {
+			t.Errorf("compile(%s): %v", tt.expr, err)
+			continue
+		}
+		actual, err := eval(document, data, env)
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+		} else if !cmp.Equal(actual, tt.expected) || len(compiler.derefs) != tt.derefs {
+			t.Errorf("%s: expected %v with %d derefs, actual %v with %d", tt.expr, tt.expected, tt.derefs, actual, len(compiler.derefs))
+		}
+	}
+
+	stmt, err := sqlparser.Parse(rewriteQuery("select title->name from posts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	eval, err := (&exprCompiler{}).compile(stmt.(*sqlparser.Select).SelectExprs[0].(*sqlparser.AliasedExpr).Expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := eval(document, data, env); err == nil {
+		t.Errorf("title->name: expected error dereferencing a string")
+	}
+
+	for _, expr := range []string{"DEREF(author, name)", "DEREF()", "DEREF(author, 'a', 'b')"} {
+		stmt, err := sqlparser.Parse("select " + expr + " from posts")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = (&exprCompiler{}).compile(stmt.(*sqlparser.Select).SelectExprs[0].(*sqlparser.AliasedExpr).Expr)
+		var parseErr *util.ParseError
+		if !errors.As(err, &parseErr) || parseErr.Code != util.CodeInvalidArgument {
+			t.Errorf("%s: expected invalid argument error, actual %v", expr, err)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package _select
+
+import (
+	"cloud.google.com/go/firestore"
+	"fmt"
+	"github.com/pgollangi/fireql/pkg/util"
+	"github.com/xwb1989/sqlparser"
+	"google.golang.org/genproto/googleapis/type/latlng"
+	"strings"
+	"time"
+)
+
+// Explain describes the Firestore query the SQL query translates to, without running it.
+// Each record is a step of the query, e.g. a filter Firestore evaluates or a condition
+// evaluated on the client side, and its detail.
+func (sel *SelectStatement) Explain() (*util.QueryResult, error) {
+	plan, err := sel.compile()
+	defer sel.closeClient()
+	if err != nil {
+		return nil, err
+	}
+	return &util.QueryResult{Columns: []string{"step", "detail"}, Records: sel.explainPlan(plan)}, nil
+}
+
+func (sel *SelectStatement) explainPlan(plan *queryPlan) [][]interface{} {
+	records := [][]interface{}{}
+	addStep := func(step string, detail string) {
+		records = append(records, []interface{}{step, detail})
+	}
+
+	if sel.join != nil {
+		// Left documents are read by a query of their own
+		records = sel.join.left.explainPlan(&queryPlan{sQuery: &sqlparser.Select{}})
+		addStep("join", sel.join.describe())
+	} else if sel.collection.Ref != nil {
+		addStep("collection", relativePath(sel.collection.Ref.Path))
+	} else if sel.collection.Parent != "" {
+		addStep("collection group", sel.collection.ID+" under "+sel.collection.Parent)
+	} else {
+		addStep("collection group", sel.collection.ID)
+	}
+
+	if plan.docRefs != nil {
+		paths := make([]string, len(plan.docRefs))
+		for idx, ref := range plan.docRefs {
+			paths[idx] = documentPath(ref)
+		}
+		addStep("lookup", "GetAll "+strings.Join(paths, ", "))
+	}
+	for _, filter := range sel.filters {
+		addStep("filter", fieldPathString(filter.path)+" "+filter.op+" "+explainValue(filter.value))
+	}
+	for _, filter := range sel.clientFilters {
+		addStep("client filter", filter.alias)
+	}
+
+	if plan.docRefs == nil && sel.join == nil {
+		if len(sel.projection) == 0 {
+			addStep("projection", "all fields")
+		} else {
+			var fields []string
+			seen := map[string]bool{}
+			for _, path := range sel.projection {
+				if field := fieldPathString(path); !seen[field] {
+					seen[field] = true
+					fields = append(fields, field)
+				}
+			}
+			addStep("projection", strings.Join(fields, ", "))
+		}
+	}
+	if len(sel.derefs) > 0 {
+		addStep("dereference", fmt.Sprintf("GetAll referenced documents for batches of up to %d documents", derefBatchSize))
+	}
+
+	orders := sel.queryOrders
+	if sel.cursorOrders != nil {
+		orders = sel.cursorOrders
+	}
+	for _, order := range orders {
+		direction := "ASC"
+		if order.direction == firestore.Desc {
+			direction = "DESC"
+		}
+		addStep("order by", fieldPathString(order.path)+" "+direction)
+	}
+	for _, order := range sel.clientOrder {
+		name := order.column.alias
+		if name == "" {
+			name = order.column.field
+		}
+		direction, nulls := "ASC", "NULLS LAST"
+		if order.desc {
+			direction = "DESC"
+		}
+		if order.nullsFirst {
+			nulls = "NULLS FIRST"
+		}
+		addStep("client order", name+" "+direction+" "+nulls)
+	}
+
+	if sel.pageToken != "" {
+		addStep("start after", sel.pageToken)
+	}
+	if sel.rowLimit > 0 {
+		if sel.clientLimit > 0 {
+			addStep("client limit", fmt.Sprint(sel.clientLimit))
+		} else {
+			addStep("limit", fmt.Sprint(sel.rowLimit))
+		}
+	}
+	if plan.sQuery.Limit != nil && plan.sQuery.Limit.Offset != nil {
+		addStep("offset", fmt.Sprintf("%s (not supported, ignored)", sqlparser.String(plan.sQuery.Limit.Offset)))
+	}
+
+	if plan.docRefs == nil && sel.join == nil {
+		if index := sel.requiredIndex(); index != nil {
+			addStep("index", index.String())
+		} else {
+			addStep("index", "single-field indexes")
+		}
+	}
+	return records
+}
+
+// explainValue formats the filter value in SQL syntax.
+func explainValue(val interface{}) string {
+	switch val := val.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return quoteString(val)
+	case time.Time:
+		return "TIMESTAMP " + quoteString(val.Format(time.RFC3339Nano))
+	case *firestore.DocumentRef:
+		return "REF(" + quoteString(documentPath(val)) + ")"
+	case *latlng.LatLng:
+		return fmt.Sprintf("GEOPOINT(%v, %v)", val.GetLatitude(), val.GetLongitude())
+	case []interface{}:
+		values := make([]string, len(val))
+		for idx, element := range val {
+			values[idx] = explainValue(element)
+		}
+		return "(" + strings.Join(values, ", ") + ")"
+	}
+	return fmt.Sprint(val)
+}
@@ -0,0 +1,121 @@
+package _select
+
+import (
+	"cloud.google.com/go/firestore"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pgollangi/fireql/pkg/util"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected [][]interface{}
+	}{
+		{
+			query: "select id, name from users where address.city = 'NY' and age > 18 order by age desc limit 10",
+			expected: [][]interface{}{
+				{"collection", "users"},
+				{"filter", "address.city == 'NY'"},
+				{"filter", "age > 18"},
+				{"projection", "id, name, age"},
+				{"order by", "age DESC"},
+				{"order by", "__name__ DESC"},
+				{"limit", "10"},
+				{"index", "users (COLLECTION): address.city ASCENDING, age DESCENDING"},
+			},
+		},
+		{
+			query: "select * from `[orders]` where tags contains 'go' and email like '%.edu'",
+			expected: [][]interface{}{
+				{"collection group", "orders"},
+				{"filter", "tags array-contains 'go'"},
+				{"client filter", "email like '%.edu'"},
+				{"projection", "all fields"},
+				{"index", "single-field indexes"},
+			},
+		},
+		{
+			query: "select title, author->name from posts where author->age > 18",
+			expected: [][]interface{}{
+				{"collection", "posts"},
+				{"client filter", "DEREF(author, 'age') > 18"},
+				{"projection", "title, author"},
+				{"dereference", "GetAll referenced documents for batches of up to 100 documents"},
+				{"index", "single-field indexes"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		actual, err := New(&util.Context{ProjectId: "test"}, tt.query).Explain()
+		if err != nil {
+			t.Errorf("%s: %v", tt.query, err)
+			continue
+		}
+		if diff := cmp.Diff([]string{"step", "detail"}, actual.Columns); diff != "" {
+			t.Errorf("%s: columns mismatch (-want +got):\n%s", tt.query, diff)
+		}
+		if diff := cmp.Diff(tt.expected, actual.Records); diff != "" {
+			t.Errorf("%s: records mismatch (-want +got):\n%s", tt.query, diff)
+		}
+	}
+}
+
+func TestValueSize(t *testing.T) {
+	data := map[string]interface{}{
+		"name":    "abc",
+		"age":     int64(30),
+		"active":  true,
+		"tags":    []interface{}{"a", "bc"},
+		"address": map[string]interface{}{"city": "NY"},
+	}
+	// name 5+4, age 4+8, active 7+1, tags 5+2+3, address 8+5+3
+	if actual := valueSize(data); actual != 55 {
+		t.Errorf("valueSize: expected 55, actual %d", actual)
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	actual, err := New(&util.Context{ProjectId: "test"}, "select id from users where id <= 5").Analyze()
+	if err != nil {
+		t.Fatal(err)
+	}
+	details := map[string]interface{}{}
+	for _, record := range actual.Records {
+		details[record[0].(string)] = record[1]
+	}
+	for step, expected := range map[string]string{"documents read": "5", "rows returned": "5"} {
+		if details[step] != expected {
+			t.Errorf("%s: expected %s, actual %v", step, expected, details[step])
+		}
+	}
+	for _, step := range []string{"index entries scanned", "client processing time"} {
+		if _, ok := details[step]; !ok {
+			t.Errorf("expected %s in %v", step, details)
+		}
+	}
+}
+
+func TestIndexEntriesScanned(t *testing.T) {
+	debugStats := map[string]interface{}{"index_entries_scanned": "12", "documents_scanned": "5"}
+	tests := []struct {
+		stats    *firestore.ExecutionStats
+		expected string
+	}{
+		{stats: &firestore.ExecutionStats{DebugStats: &debugStats}, expected: "12"},
+		{stats: &firestore.ExecutionStats{}, expected: "n/a (not reported by Firestore)"},
+	}
+	for _, tt := range tests {
+		stats := &queryStats{queries: 1}
+		if entries, ok := indexEntriesScanned(tt.stats); ok {
+			stats.indexEntries, stats.explainedQueries = entries, 1
+		}
+		if actual := stats.formatIndexEntries(); actual != tt.expected {
+			t.Errorf("formatIndexEntries: expected %q, actual %q", tt.expected, actual)
+		}
+	}
+	stats := &queryStats{indexEntries: 3, queries: 2, explainedQueries: 1}
+	if expected, actual := "3 (reported for 1 of 2 queries)", stats.formatIndexEntries(); actual != expected {
+		t.Errorf("formatIndexEntries: expected %q, actual %q", expected, actual)
+	}
+}
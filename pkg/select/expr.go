@@ -0,0 +1,803 @@
+package _select
+
+import (
+	"cloud.google.com/go/firestore"
+	"fmt"
+	"github.com/pgollangi/fireql/pkg/support"
+	"github.com/pgollangi/fireql/pkg/util"
+	"github.com/xwb1989/sqlparser"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// evaluator evaluates a compiled expression on a document, in the environment of the query run.
+// NULL, missing fields and the unknown truth value of SQL three-valued logic all evaluate to nil.
+type evaluator func(document *firestore.DocumentSnapshot, data map[string]interface{}, env *evalEnv) (interface{}, error)
+
+// evalEnv is the environment expressions are evaluated in by a query run.
+type evalEnv struct {
+	// values of query parameters
+	binds bindVars
+	// documents dereferenced by the query run
+	refs *refCache
+}
+
+// exprCompiler compiles SQL expressions into evaluators once per query,
+// collecting the fields they refer to.
+type exprCompiler struct {
+	functions *support.Functions
+	fields    []firestore.FieldPath
+	// references dereferenced by the expressions, nested ones first
+	derefs []evaluator
+}
+
+func (c *exprCompiler) compile(expr sqlparser.Expr) (evaluator, error) {
+	switch expr := expr.(type) {
+	case *sqlparser.ColName:
+		path, err := colFieldPath(expr)
+		if err != nil {
+			return nil, err
+		}
+		c.fields = append(c.fields, path)
+		return func(document *firestore.DocumentSnapshot, data map[string]interface{}, env *evalEnv) (interface{}, error) {
+			val, _ := lookupField(document, data, path)
+			return val, nil
+		}, nil
+	case *sqlparser.SQLVal:
+		if expr.Type == sqlparser.ValArg {
+			name := bindVarName(expr)
+			return func(document *firestore.DocumentSnapshot, data map[string]interface{}, env *evalEnv) (interface{}, error) {
+				return env.binds[name], nil
+			}, nil
+		}
+		val, err := literalValue(expr)
+		if err != nil {
+			return nil, err
+		}
+		return constant(val), nil
+	case sqlparser.BoolVal:
+		return constant(bool(expr)), nil
+	case *sqlparser.NullVal:
+		return constant(nil), nil
+	case *sqlparser.ParenExpr:
+		return c.compile(expr.Expr)
+	case *sqlparser.AndExpr:
+		return c.compileLogical("AND", expr.Left, expr.Right)
+	case *sqlparser.OrExpr:
+		return c.compileLogical("OR", expr.Left, expr.Right)
+	case *sqlparser.NotExpr:
+		return c.compileNot(expr.Expr)
+	case *sqlparser.UnaryExpr:
+		return c.compileUnary(expr)
+	case *sqlparser.BinaryExpr:
+		if interval, ok := expr.Right.(*sqlparser.IntervalExpr); ok {
+			return c.compileTimestampAdd(expr, interval)
+		}
+		return c.compileArithmetic(expr)
+	case *sqlparser.ComparisonExpr:
+		return c.compileComparison(expr)
+	case *sqlparser.RangeCond:
+		return c.compileRange(expr)
+	case *sqlparser.IsExpr:
+		return c.compileIs(expr)
+	case *sqlparser.CaseExpr:
+		return c.compileCase(expr)
+	case *sqlparser.FuncExpr:
+		return c.compileFunc(expr)
+	}
+	return nil, util.NewUnsupportedError(util.CodeUnsupportedExpression, sqlparser.String(expr), "unsupported expression: %s", sqlparser.String(expr))
+}
+
+func constant(val interface{}) evaluator {
+	return func(document *firestore.DocumentSnapshot, data map[string]interface{}, env *evalEnv) (interface{}, error) {
+		return val, nil
+	}
+}
+
+// literalValue returns the value of the literal, integers being int64 like Firestore integers.
+func literalValue(expr *sqlparser.SQLVal) (interface{}, error) {
+	switch expr.Type {
+	case sqlparser.StrVal:
+		return string(expr.Val), nil
+	case sqlparser.IntVal:
+		if val, err := strconv.ParseInt(string(expr.Val), 10, 64); err == nil {
+			return val, nil
+		}
+		// out of range integers are doubles
+		fallthrough
+	case sqlparser.FloatVal:
+		val, err := strconv.ParseFloat(string(expr.Val), 64)
+		if err != nil {
+			return nil, util.NewParseError(util.CodeInvalidArgument, sqlparser.String(expr), "invalid number %s", sqlparser.String(expr))
+		}
+		return val, nil
+	}
+	return nil, util.NewUnsupportedError(util.CodeUnsupportedExpression, sqlparser.String(expr), "unsupported value: %s", sqlparser.String(expr))
+}
+
+// lookupField returns value of the field at the path in the document, and whether the field exists.
+func lookupField(document *firestore.DocumentSnapshot, data map[string]interface{}, path firestore.FieldPath) (interface{}, bool) {
+	if isDocumentID(path) {
+		return document.Ref.ID, true
+	}
+	var val interface{} = data
+	for _, segment := range path {
+		fieldMap, ok := val.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if val, ok = fieldMap[segment]; !ok {
+			return nil, false
+		}
+		if val == nil {
+			break
+		}
+	}
+	return val, true
+}
+
+func (c *exprCompiler) compileAll(exprs []sqlparser.Expr) ([]evaluator, error) {
+	evaluators := make([]evaluator, len(exprs))
+	for idx, expr := range exprs {
+		eval, err := c.compile(expr)
+		if err != nil {
+			return nil, err
+		}
+		evaluators[idx] = eval
+	}
+	return evaluators, nil
+}
+
+// compileLogical compiles AND and OR with three-valued logic, e.g. FALSE AND NULL is FALSE
+// while TRUE AND NULL is NULL. The right operand isn't evaluated when the left decides the result.
+func (c *exprCompiler) compileLogical(op string, leftExpr sqlparser.Expr, rightExpr sqlparser.Expr) (evaluator, error) {
+	left, err := c.compile(leftExpr)
+	if err != nil {
+		return nil, err
+	}
+	right, err := c.compile(rightExpr)
+	if err != nil {
+		return nil, err
+	}
+	// FALSE decides AND, TRUE decides OR
+	decisive := op == "OR"
+	return func(document *firestore.DocumentSnapshot, data map[string]interface{}, env *evalEnv) (interface{}, error) {
+		leftVal, err := evalBool(op, left, document, data, env)
+		if err != nil || leftVal == decisive {
+			return leftVal, err
+		}
+		rightVal, err := evalBool(op, right, document, data, env)
+		if err != nil || rightVal == decisive {
+			return rightVal, err
+		}
+		if leftVal == nil || rightVal == nil {
+			return nil, nil
+		}
+		return !decisive, nil
+	}, nil
+}
+
+// evalBool evaluates the operand of the logical operator, which must be a boolean or NULL.
+func evalBool(op string, eval evaluator, document *firestore.DocumentSnapshot, data map[string]interface{}, env *evalEnv) (interface{}, error) {
+	val, err := eval(document, data, env)
+	if err != nil {
+		return nil, err
+	}
+	switch val.(type) {
+	case nil, bool:
+		return val, nil
+	}
+	return nil, fmt.Errorf("%s expects boolean operands, got %s", op, support.FirestoreType(val))
+}
+
+func (c *exprCompiler) compileNot(expr sqlparser.Expr) (evaluator, error) {
+	inner, err := c.compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return func(document *firestore.DocumentSnapshot, data map[string]interface{}, env *evalEnv) (interface{}, error) {
+		val, err := evalBool("NOT", inner, document, data, env)
+		if b, ok := val.(bool); ok {
+			return !b, err
+		}
+		return val, err
+	}, nil
+}
+
+func (c *exprCompiler) compileUnary(expr *sqlparser.UnaryExpr) (evaluator, error) {
+	if expr.Operator == sqlparser.BangStr {
+		return c.compileNot(expr.Expr)
+	}
+	switch expr.Operator {
+	case sqlparser.UMinusStr, sqlparser.UPlusStr, sqlparser.TildaStr:
+	default:
+		return nil, util.NewUnsupportedError(util.CodeUnsupportedExpression, sqlparser.String(expr), "unsupported expression: %s", sqlparser.String(expr))
+	}
+	inner, err := c.compile(expr.Expr)
+	if err != nil {
+		return nil, err
+	}
+	op := expr.Operator
+	return func(document *firestore.DocumentSnapshot, data map[string]interface{}, env *evalEnv) (interface{}, error) {
+		val, err := inner(document, data, env)
+		if err != nil || val == nil {
+			return nil, err
+		}
+		if i, ok := intValue(val); ok {
+			switch op {
+			case sqlparser.UMinusStr:
+				return -i, nil
+			case sqlparser.TildaStr:
+				return ^i, nil
+			}
+			return i, nil
+		}
+		if f, ok := val.(float64); ok && op != sqlparser.TildaStr {
+			if op == sqlparser.UMinusStr {
+				return -f, nil
+			}
+			return f, nil
+		}
+		return nil, fmt.Errorf("operator %s expects a number, got %s", op, support.FirestoreType(val))
+	}, nil
+}
+
+// arithmeticOperators are the supported arithmetic and bitwise operators.
+var arithmeticOperators = map[string]bool{
+	sqlparser.PlusStr: true, sqlparser.MinusStr: true, sqlparser.MultStr: true, sqlparser.DivStr: true,
+	sqlparser.IntDivStr: true, sqlparser.ModStr: true, sqlparser.BitAndStr: true, sqlparser.BitOrStr: true,
+	sqlparser.BitXorStr: true, sqlparser.ShiftLeftStr: true, sqlparser.ShiftRightStr: true,
+}
+
+func (c *exprCompiler) compileArithmetic(expr *sqlparser.BinaryExpr) (evaluator, error) {
+	if !arithmeticOperators[expr.Operator] {
+		return nil, util.NewUnsupportedError(util.CodeUnsupportedExpression, sqlparser.String(expr), "unsupported operator %s: %s", expr.Operator, sqlparser.String(expr))
+	}
+	left, err := c.compile(expr.Left)
+	if err != nil {
+		return nil, err
+	}
+	right, err := c.compile(expr.Right)
+	if err != nil {
+		return nil, err
+	}
+	op := expr.Operator
+	return func(document *firestore.DocumentSnapshot, data map[string]interface{}, env *evalEnv) (interface{}, error) {
+		leftVal, err := left(document, data, env)
+		if err != nil || leftVal == nil {
+			return nil, err
+		}
+		rightVal, err := right(document, data, env)
+		if err != nil || rightVal == nil {
+			return nil, err
+		}
+		return arithmetic(op, leftVal, rightVal)
+	}, nil
+}
+
+// arithmetic applies the operator to numbers. Integer operands give integers, except for /.
+// Like in MySQL, division by zero is NULL.
+func arithmetic(op string, left interface{}, right interface{}) (interface{}, error) {
+	leftInt, leftIsInt := intValue(left)
+	rightInt, rightIsInt := intValue(right)
+	if leftIsInt && rightIsInt {
+		switch op {
+		case sqlparser.PlusStr:
+			return leftInt + rightInt, nil
+		case sqlparser.MinusStr:
+			return leftInt - rightInt, nil
+		case sqlparser.MultStr:
+			return leftInt * rightInt, nil
+		case sqlparser.IntDivStr, sqlparser.ModStr:
+			if rightInt == 0 {
+				return nil, nil
+			}
+			if op == sqlparser.ModStr {
+				return leftInt % rightInt, nil
+			}
+			return leftInt / rightInt, nil
+		case sqlparser.BitAndStr:
+			return leftInt & rightInt, nil
+		case sqlparser.BitOrStr:
+			return leftInt | rightInt, nil
+		case sqlparser.BitXorStr:
+			return leftInt ^ rightInt, nil
+		case sqlparser.ShiftLeftStr, sqlparser.ShiftRightStr:
+			if rightInt < 0 {
+				return nil, fmt.Errorf("operator %s expects a non-negative shift, got %d", op, rightInt)
+			}
+			if op == sqlparser.ShiftLeftStr {
+				return leftInt << uint64(rightInt), nil
+			}
+			return leftInt >> uint64(rightInt), nil
+		}
+	}
+	leftNum, leftIsNum := numberValue(left)
+	rightNum, rightIsNum := numberValue(right)
+	if !leftIsNum || !rightIsNum {
+		return nil, fmt.Errorf("operator %s expects numbers, got %s and %s", op, support.FirestoreType(left), support.FirestoreType(right))
+	}
+	switch op {
+	case sqlparser.PlusStr:
+		return leftNum + rightNum, nil
+	case sqlparser.MinusStr:
+		return leftNum - rightNum, nil
+	case sqlparser.MultStr:
+		return leftNum * rightNum, nil
+	case sqlparser.DivStr, sqlparser.IntDivStr, sqlparser.ModStr:
+		if rightNum == 0 {
+			return nil, nil
+		}
+		switch op {
+		case sqlparser.IntDivStr:
+			return int64(math.Trunc(leftNum / rightNum)), nil
+		case sqlparser.ModStr:
+			return math.Mod(leftNum, rightNum), nil
+		}
+		return leftNum / rightNum, nil
+	}
+	return nil, fmt.Errorf("operator %s expects integers, got %s and %s", op, support.FirestoreType(left), support.FirestoreType(right))
+}
+
+func intValue(val interface{}) (int64, bool) {
+	switch val := val.(type) {
+	case int:
+		return int64(val), true
+	case int32:
+		return int64(val), true
+	case int64:
+		return val, true
+	}
+	return 0, false
+}
+
+// compileTimestampAdd compiles timestamp arithmetic with intervals, e.g. created_at + INTERVAL 1 DAY,
+// into TIMESTAMP_ADD or TIMESTAMP_SUB calls.
+func (c *exprCompiler) compileTimestampAdd(expr *sqlparser.BinaryExpr, interval *sqlparser.IntervalExpr) (evaluator, error) {
+	name := "TIMESTAMP_ADD"
+	switch expr.Operator {
+	case sqlparser.PlusStr:
+	case sqlparser.MinusStr:
+		name = "TIMESTAMP_SUB"
+	default:
+		return nil, util.NewUnsupportedError(util.CodeUnsupportedExpression, sqlparser.String(expr), "unsupported expression: %s", sqlparser.String(expr))
+	}
+	timestamp, err := c.compile(expr.Left)
+	if err != nil {
+		return nil, err
+	}
+	amount, err := c.compile(interval.Expr)
+	if err != nil {
+		return nil, err
+	}
+	return c.compileCall(expr, name, []evaluator{timestamp, amount, constant(strings.ToUpper(interval.Unit))})
+}
+
+func (c *exprCompiler) compileComparison(expr *sqlparser.ComparisonExpr) (evaluator, error) {
+	switch expr.Operator {
+	case sqlparser.LikeStr, sqlparser.NotLikeStr, sqlparser.RegexpStr, sqlparser.NotRegexpStr:
+		return c.compileMatch(expr)
+	case sqlparser.InStr, sqlparser.NotInStr:
+		return c.compileIn(expr)
+	case sqlparser.EqualStr, sqlparser.NotEqualStr, "<>", sqlparser.NullSafeEqualStr,
+		sqlparser.LessThanStr, sqlparser.GreaterThanStr, sqlparser.LessEqualStr, sqlparser.GreaterEqualStr:
+	default:
+		return nil, util.NewUnsupportedError(util.CodeUnsupportedExpression, sqlparser.String(expr), "unsupported operator %s: %s", expr.Operator, sqlparser.String(expr))
+	}
+	left, err := c.compile(expr.Left)
+	if err != nil {
+		return nil, err
+	}
+	right, err := c.compile(expr.Right)
+	if err != nil {
+		return nil, err
+	}
+	op := expr.Operator
+	return func(document *firestore.DocumentSnapshot, data map[string]interface{}, env *evalEnv) (interface{}, error) {
+		leftVal, err := left(document, data, env)
+		if err != nil {
+			return nil, err
+		}
+		rightVal, err := right(document, data, env)
+		if err != nil {
+			return nil, err
+		}
+		return compare(op, leftVal, rightVal), nil
+	}, nil
+}
+
+// compare compares the values with the operator. Comparisons with NULL are NULL, except for <=>.
+// Values of different types are not equal and, as in Firestore filters, not ordered.
+func compare(op string, left interface{}, right interface{}) interface{} {
+	if op == sqlparser.NullSafeEqualStr {
+		if left == nil || right == nil {
+			return left == nil && right == nil
+		}
+		op = sqlparser.EqualStr
+	}
+	if left == nil || right == nil {
+		return nil
+	}
+	if typeOrder[support.FirestoreType(left)] != typeOrder[support.FirestoreType(right)] {
+		switch op {
+		case sqlparser.EqualStr:
+			return false
+		case sqlparser.NotEqualStr, "<>":
+			return true
+		}
+		return nil
+	}
+	cmp := compareValues(left, right)
+	switch op {
+	case sqlparser.EqualStr:
+		return cmp == 0
+	case sqlparser.NotEqualStr, "<>":
+		return cmp != 0
+	case sqlparser.LessThanStr:
+		return cmp < 0
+	case sqlparser.LessEqualStr:
+		return cmp <= 0
+	case sqlparser.GreaterThanStr:
+		return cmp > 0
+	}
+	return cmp >= 0
+}
+
+// compileMatch compiles LIKE and REGEXP with a constant pattern. Values other than strings don't match.
+func (c *exprCompiler) compileMatch(expr *sqlparser.ComparisonExpr) (evaluator, error) {
+	left, err := c.compile(expr.Left)
+	if err != nil {
+		return nil, err
+	}
+	pattern, ok := expr.Right.(*sqlparser.SQLVal)
+	if !ok || pattern.Type != sqlparser.StrVal {
+		return nil, util.NewParseError(util.CodeInvalidArgument, sqlparser.String(expr), "%s expects a string pattern: %s", strings.ToUpper(expr.Operator), sqlparser.String(expr))
+	}
+	expression := string(pattern.Val)
+	if expr.Operator == sqlparser.LikeStr || expr.Operator == sqlparser.NotLikeStr {
+		escape, err := likeEscape(expr)
+		if err != nil {
+			return nil, err
+		}
+		expression = likeToRegexp(expression, escape, false)
+	}
+	regex, err := regexp.Compile(expression)
+	if err != nil {
+		return nil, util.NewParseError(util.CodeInvalidArgument, sqlparser.String(pattern), "invalid pattern %s: %v", sqlparser.String(pattern), err)
+	}
+	negated := expr.Operator == sqlparser.NotLikeStr || expr.Operator == sqlparser.NotRegexpStr
+	return func(document *firestore.DocumentSnapshot, data map[string]interface{}, env *evalEnv) (interface{}, error) {
+		val, err := left(document, data, env)
+		if err != nil || val == nil {
+			return nil, err
+		}
+		str, ok := val.(string)
+		if !ok {
+			return nil, nil
+		}
+		return regex.MatchString(str) != negated, nil
+	}, nil
+}
+
+// compileIn compiles IN with a list of values. When no value is equal, IN is NULL
+// if the list has NULL, like comparing with each value.
+func (c *exprCompiler) compileIn(expr *sqlparser.ComparisonExpr) (evaluator, error) {
+	tuple, ok := expr.Right.(sqlparser.ValTuple)
+	if !ok {
+		return nil, util.NewUnsupportedError(util.CodeUnsupportedExpression, sqlparser.String(expr), "%s expects a list of values: %s", strings.ToUpper(expr.Operator), sqlparser.String(expr))
+	}
+	left, err := c.compile(expr.Left)
+	if err != nil {
+		return nil, err
+	}
+	values, err := c.compileAll(tuple)
+	if err != nil {
+		return nil, err
+	}
+	negated := expr.Operator == sqlparser.NotInStr
+	return func(document *firestore.DocumentSnapshot, data map[string]interface{}, env *evalEnv) (interface{}, error) {
+		leftVal, err := left(document, data, env)
+		if err != nil || leftVal == nil {
+			return nil, err
+		}
+		hasNull := false
+		for _, value := range values {
+			val, err := value(document, data, env)
+			if err != nil {
+				return nil, err
+			}
+			switch compare(sqlparser.EqualStr, leftVal, val) {
+			case true:
+				return !negated, nil
+			case nil:
+				hasNull = true
+			}
+		}
+		if hasNull {
+			return nil, nil
+		}
+		return negated, nil
+	}, nil
+}
+
+func (c *exprCompiler) compileRange(expr *sqlparser.RangeCond) (evaluator, error) {
+	evaluators, err := c.compileAll([]sqlparser.Expr{expr.Left, expr.From, expr.To})
+	if err != nil {
+		return nil, err
+	}
+	left, from, to := evaluators[0], evaluators[1], evaluators[2]
+	negated := expr.Operator == sqlparser.NotBetweenStr
+	return func(document *firestore.DocumentSnapshot, data map[string]interface{}, env *evalEnv) (interface{}, error) {
+		leftVal, err := left(document, data, env)
+		if err != nil {
+			return nil, err
+		}
+		fromVal, err := from(document, data, env)
+		if err != nil {
+			return nil, err
+		}
+		toVal, err := to(document, data, env)
+		if err != nil {
+			return nil, err
+		}
+		// BETWEEN is a closed range
+		lower, upper := compare(sqlparser.GreaterEqualStr, leftVal, fromVal), compare(sqlparser.LessEqualStr, leftVal, toVal)
+		if lower == false || upper == false {
+			return negated, nil
+		}
+		if lower == nil || upper == nil {
+			return nil, nil
+		}
+		return !negated, nil
+	}, nil
+}
+
+func (c *exprCompiler) compileIs(expr *sqlparser.IsExpr) (evaluator, error) {
+	inner, err := c.compile(expr.Expr)
+	if err != nil {
+		return nil, err
+	}
+	var is func(val interface{}) bool
+	switch expr.Operator {
+	case sqlparser.IsNullStr:
+		is = func(val interface{}) bool { return val == nil }
+	case sqlparser.IsNotNullStr:
+		is = func(val interface{}) bool { return val != nil }
+	case sqlparser.IsTrueStr:
+		is = func(val interface{}) bool { return val == true }
+	case sqlparser.IsNotTrueStr:
+		is = func(val interface{}) bool { return val != true }
+	case sqlparser.IsFalseStr:
+		is = func(val interface{}) bool { return val == false }
+	case sqlparser.IsNotFalseStr:
+		is = func(val interface{}) bool { return val != false }
+	default:
+		return nil, util.NewUnsupportedError(util.CodeUnsupportedExpression, sqlparser.String(expr), "unsupported operator %s: %s", expr.Operator, sqlparser.String(expr))
+	}
+	return func(document *firestore.DocumentSnapshot, data map[string]interface{}, env *evalEnv) (interface{}, error) {
+		val, err := inner(document, data, env)
+		if err != nil {
+			return nil, err
+		}
+		return is(val), nil
+	}, nil
+}
+
+// compileCase compiles CASE, evaluating to NULL when no condition is true and there's no ELSE.
+// Conditions that are NULL are false. Only the value of the chosen branch is evaluated.
+func (c *exprCompiler) compileCase(expr *sqlparser.CaseExpr) (evaluator, error) {
+	subject := constant(nil)
+	if expr.Expr != nil {
+		var err error
+		if subject, err = c.compile(expr.Expr); err != nil {
+			return nil, err
+		}
+	}
+	conds := make([]evaluator, len(expr.Whens))
+	vals := make([]evaluator, len(expr.Whens))
+	for idx, when := range expr.Whens {
+		var err error
+		if conds[idx], err = c.compile(when.Cond); err != nil {
+			return nil, err
+		}
+		if vals[idx], err = c.compile(when.Val); err != nil {
+			return nil, err
+		}
+	}
+	elseVal := constant(nil)
+	if expr.Else != nil {
+		var err error
+		if elseVal, err = c.compile(expr.Else); err != nil {
+			return nil, err
+		}
+	}
+	simple := expr.Expr != nil
+	return func(document *firestore.DocumentSnapshot, data map[string]interface{}, env *evalEnv) (interface{}, error) {
+		subjectVal, err := subject(document, data, env)
+		if err != nil {
+			return nil, err
+		}
+		for idx, cond := range conds {
+			var matched interface{}
+			if simple {
+				// CASE x WHEN 1 THEN ...
+				condVal, err := cond(document, data, env)
+				if err != nil {
+					return nil, err
+				}
+				matched = compare(sqlparser.EqualStr, subjectVal, condVal)
+			} else if matched, err = evalBool("CASE WHEN", cond, document, data, env); err != nil {
+				return nil, err
+			}
+			if matched == true {
+				return vals[idx](document, data, env)
+			}
+		}
+		return elseVal(document, data, env)
+	}, nil
+}
+
+func (c *exprCompiler) compileFunc(expr *sqlparser.FuncExpr) (evaluator, error) {
+	name := expr.Name.String()
+	if expr.Distinct {
+		return nil, util.NewUnsupportedError(util.CodeUnsupportedExpression, sqlparser.String(expr), "unsupported DISTINCT in %s", sqlparser.String(expr))
+	}
+	if expr.Name.Lowered() == "deref" {
+		return c.compileDeref(expr)
+	}
+	if !c.functions.Exists(name) {
+		return nil, util.NewUnsupportedError(util.CodeUnsupportedFunction, sqlparser.String(expr), `unknown function "%s"`, strings.ToUpper(name))
+	}
+	var args []evaluator
+	for _, arg := range expr.Exprs {
+		aliasedArg, ok := arg.(*sqlparser.AliasedExpr)
+		if !ok {
+			return nil, util.NewParseError(util.CodeInvalidArgument, sqlparser.String(arg), "unsupported argument %s to %s", sqlparser.String(arg), strings.ToUpper(name))
+		}
+		argExpr := aliasedArg.Expr
+		interval, isInterval := argExpr.(*sqlparser.IntervalExpr)
+		if isInterval {
+			// amount and unit arguments of date functions
+			argExpr = interval.Expr
+		}
+		eval, err := c.compile(argExpr)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, eval)
+		if isInterval {
+			args = append(args, constant(strings.ToUpper(interval.Unit)))
+		}
+	}
+	if expr.Name.Lowered() == "if" && len(args) == 3 {
+		return compileIf(args), nil
+	}
+	return c.compileCall(expr, name, args)
+}
+
+// compileCall compiles the call of the registered function.
+func (c *exprCompiler) compileCall(expr sqlparser.Expr, name string, args []evaluator) (evaluator, error) {
+	if err := c.functions.ValidateParams(name, len(args)); err != nil {
+		return nil, util.NewParseError(util.CodeInvalidArgument, sqlparser.String(expr), "%v", err)
+	}
+	function, err := c.functions.Bind(name)
+	if err != nil {
+		return nil, util.NewUnsupportedError(util.CodeUnsupportedFunction, sqlparser.String(expr), "%v", err)
+	}
+	return func(document *firestore.DocumentSnapshot, data map[string]interface{}, env *evalEnv) (interface{}, error) {
+		params := make([]interface{}, len(args))
+		for idx, arg := range args {
+			val, err := arg(document, data, env)
+			if err != nil {
+				return nil, err
+			}
+			params[idx] = val
+		}
+		return function(params)
+	}, nil
+}
+
+// compileIf compiles IF(condition, then, else), which only evaluates the chosen branch.
+func compileIf(args []evaluator) evaluator {
+	return func(document *firestore.DocumentSnapshot, data map[string]interface{}, env *evalEnv) (interface{}, error) {
+		cond, err := evalBool("IF", args[0], document, data, env)
+		if err != nil {
+			return nil, err
+		}
+		if cond == true {
+			return args[1](document, data, env)
+		}
+		return args[2](document, data, env)
+	}
+}
+
+// defaultLikeEscape escapes wildcards of LIKE patterns without an ESCAPE clause.
+const defaultLikeEscape = '\\'
+
+// likeEscape returns the escape character of the LIKE condition, given by its ESCAPE
+// clause or else the backslash. An empty ESCAPE clause disables escaping, returning -1.
+func likeEscape(expr *sqlparser.ComparisonExpr) (rune, error) {
+	if expr.Escape == nil {
+		return defaultLikeEscape, nil
+	}
+	escape, ok := expr.Escape.(*sqlparser.SQLVal)
+	if !ok || escape.Type != sqlparser.StrVal || utf8.RuneCount(escape.Val) > 1 {
+		return 0, util.NewParseError(util.CodeInvalidArgument, sqlparser.String(expr.Escape), "ESCAPE expects a single character: %s", sqlparser.String(expr))
+	}
+	if len(escape.Val) == 0 {
+		return -1, nil
+	}
+	r, _ := utf8.DecodeRune(escape.Val)
+	return r, nil
+}
+
+// likeToRegexp converts LIKE pattern into an equivalent regular expression.
+// % matches any sequence of characters and _ matches any single character,
+// unless escaped by the escape character.
+func likeToRegexp(pattern string, escape rune, caseInsensitive bool) string {
+	var sb strings.Builder
+	sb.WriteString("(?s")
+	if caseInsensitive {
+		sb.WriteString("i")
+	}
+	sb.WriteString(")^")
+	for i := 0; i < len(pattern); {
+		r, size := utf8.DecodeRuneInString(pattern[i:])
+		i += size
+		switch {
+		case r == escape && i < len(pattern):
+			r, size = utf8.DecodeRuneInString(pattern[i:])
+			i += size
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		case r == '%':
+			sb.WriteString(".*")
+		case r == '_':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+	return sb.String()
+}
+
+// likePrefix splits LIKE pattern at its first wildcard into the literal prefix, with
+// escaped characters unescaped, and the rest of the pattern, e.g. "ab" and "%" for 'ab%'.
+// Patterns of the form 'prefix%' can be served by Firestore as a range filter.
+func likePrefix(pattern string, escape rune) (string, string) {
+	var sb strings.Builder
+	for i := 0; i < len(pattern); {
+		r, size := utf8.DecodeRuneInString(pattern[i:])
+		switch {
+		case r == escape && i+size < len(pattern):
+			i += size
+			r, size = utf8.DecodeRuneInString(pattern[i:])
+		case r == '%' || r == '_':
+			return sb.String(), pattern[i:]
+		}
+		sb.WriteRune(r)
+		i += size
+	}
+	return sb.String(), ""
+}
+
+// prefixUpperBound returns the smallest string greater than all strings
+// starting with the prefix, e.g. "abd" for "abc".
+func prefixUpperBound(prefix string) (string, bool) {
+	runes := []rune(prefix)
+	for i := len(runes) - 1; i >= 0; i-- {
+		if runes[i] < utf8.MaxRune {
+			runes[i]++
+			if utf8.ValidRune(runes[i]) {
+				return string(runes[:i+1]), true
+			}
+			// skip the surrogate range
+			runes[i] = 0xE000
+			return string(runes[:i+1]), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,228 @@
+package _select
+
+import (
+	"cloud.google.com/go/firestore"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pgollangi/fireql/pkg/util"
+	"github.com/xwb1989/sqlparser"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestLikeToRegexp(t *testing.T) {
+	tests := []struct {
+		pattern string
+		escape  rune
+		matches []string
+		misses  []string
+	}{
+		{pattern: "abc%", matches: []string{"abc", "abcdef"}, misses: []string{"xabc", "ABC"}},
+		{pattern: "%.edu", matches: []string{"a@psu.edu"}, misses: []string{"a@psu.educ", "a@psuxedu"}},
+		{pattern: "a_c", matches: []string{"abc", "a c"}, misses: []string{"ac", "abbc"}},
+		{pattern: `100\%`, matches: []string{"100%"}, misses: []string{"1000"}},
+		{pattern: "a!_%", escape: '!', matches: []string{"a_", "a_bc"}, misses: []string{"abc", "a!bc"}},
+		{pattern: `a\%`, escape: -1, matches: []string{`a\`, `a\bc`}, misses: []string{"a%"}},
+	}
+	for _, tt := range tests {
+		escape := tt.escape
+		if escape == 0 {
+			escape = defaultLikeEscape
+		}
+		regex := regexp.MustCompile(likeToRegexp(tt.pattern, escape, false))
+		for _, val := range tt.matches {
+			if !regex.MatchString(val) {
+				t.Errorf("LIKE '%s' expected to match %s", tt.pattern, val)
+			}
+		}
+		for _, val := range tt.misses {
+			if regex.MatchString(val) {
+				t.Errorf("LIKE '%s' expected not to match %s", tt.pattern, val)
+			}
+		}
+	}
+	if !regexp.MustCompile(likeToRegexp("abc%", defaultLikeEscape, true)).MatchString("ABCD") {
+		t.Errorf("ILIKE 'abc%%' expected to match ABCD")
+	}
+}
+
+func TestLikePrefix(t *testing.T) {
+	tests := []struct {
+		pattern    string
+		escape     rune
+		prefix     string
+		rest       string
+		upperBound string
+	}{
+		{pattern: "abc%", prefix: "abc", rest: "%", upperBound: "abd"},
+		{pattern: "az%", prefix: "az", rest: "%", upperBound: "a{"},
+		{pattern: "%abc", rest: "%abc"},
+		{pattern: "a_c%", prefix: "a", rest: "_c%"},
+		{pattern: "abc", prefix: "abc"},
+		{pattern: `a\_b%`, prefix: "a_b", rest: "%", upperBound: "a_c"},
+		{pattern: "a!_%", escape: '!', prefix: "a_", rest: "%", upperBound: "a`"},
+		{pattern: `a\_%`, escape: '!', prefix: `a\`, rest: "_%"},
+	}
+	for _, tt := range tests {
+		escape := tt.escape
+		if escape == 0 {
+			escape = defaultLikeEscape
+		}
+		prefix, rest := likePrefix(tt.pattern, escape)
+		if prefix != tt.prefix || rest != tt.rest {
+			t.Errorf("likePrefix(%s): expected %q, %q, actual %q, %q", tt.pattern, tt.prefix, tt.rest, prefix, rest)
+			continue
+		}
+		if upperBound, _ := prefixUpperBound(prefix); rest == "%" && upperBound != tt.upperBound {
+			t.Errorf("prefixUpperBound(%s): expected %q, actual %q", prefix, tt.upperBound, upperBound)
+		}
+	}
+}
+
+func TestCompileExpr(t *testing.T) {
+	data := map[string]interface{}{
+		"name":    "x",
+		"age":     int64(30),
+		"score":   2.5,
+		"nick":    nil,
+		"active":  true,
+		"address": map[string]interface{}{"city": "y"},
+	}
+	tests := []struct {
+		expr     string
+		expected interface{}
+		fields   []firestore.FieldPath
+	}{
+		{
+			expr:     "name = 'x' and (`address.city` != 'y' or not age in (1, 2))",
+			expected: true,
+			fields:   []firestore.FieldPath{{"name"}, {"address", "city"}, {"age"}},
+		},
+		{expr: "`address`.city = 'y'", expected: true, fields: []firestore.FieldPath{{"address", "city"}}},
+		{expr: "age + 1", expected: int64(31), fields: []firestore.FieldPath{{"age"}}},
+		{expr: "age / 4", expected: 7.5, fields: []firestore.FieldPath{{"age"}}},
+		{expr: "age div 4 + age % 4", expected: int64(9), fields: []firestore.FieldPath{{"age"}, {"age"}}},
+		{expr: "age * score - -1", expected: 76.0, fields: []firestore.FieldPath{{"age"}, {"score"}}},
+		{expr: "age / 0", expected: nil, fields: []firestore.FieldPath{{"age"}}},
+		{expr: "age = 30.0 and age <=> 30 and nick <=> null", expected: true, fields: []firestore.FieldPath{{"age"}, {"age"}, {"nick"}}},
+		{expr: "age = '30'", expected: false, fields: []firestore.FieldPath{{"age"}}},
+		{expr: "age > '30'", expected: nil, fields: []firestore.FieldPath{{"age"}}},
+		{expr: "nick = 'a'", expected: nil, fields: []firestore.FieldPath{{"nick"}}},
+		{expr: "missing > 1 and false", expected: false, fields: []firestore.FieldPath{{"missing"}}},
+		{expr: "missing > 1 or true", expected: true, fields: []firestore.FieldPath{{"missing"}}},
+		{expr: "missing > 1 or false", expected: nil, fields: []firestore.FieldPath{{"missing"}}},
+		{expr: "not (missing > 1)", expected: nil, fields: []firestore.FieldPath{{"missing"}}},
+		{expr: "age in (1, null)", expected: nil, fields: []firestore.FieldPath{{"age"}}},
+		{expr: "age not in (1, 2)", expected: true, fields: []firestore.FieldPath{{"age"}}},
+		{expr: "age not between 18 and 65", expected: false, fields: []firestore.FieldPath{{"age"}}},
+		{expr: "age between 18 and nick", expected: nil, fields: []firestore.FieldPath{{"age"}, {"nick"}}},
+		{expr: "nick is null and active is true and missing is not false", expected: true, fields: []firestore.FieldPath{{"nick"}, {"active"}, {"missing"}}},
+		{expr: "name like 'X%' or name regexp '^x$'", expected: true, fields: []firestore.FieldPath{{"name"}, {"name"}}},
+		{expr: "age like '3%'", expected: nil, fields: []firestore.FieldPath{{"age"}}},
+		{expr: "name like 'x!%' escape '!'", expected: false, fields: []firestore.FieldPath{{"name"}}},
+		{expr: "name like 'x%' escape '!'", expected: true, fields: []firestore.FieldPath{{"name"}}},
+		{expr: "length(name) * 2 >= 2", expected: true, fields: []firestore.FieldPath{{"name"}}},
+		{expr: "age = ? and name = :name and ? is null", expected: true, fields: []firestore.FieldPath{{"age"}, {"name"}}},
+	}
+	binds := bindVars{"v1": int64(30), "name": "x", "v2": nil}
+	document := &firestore.DocumentSnapshot{Ref: &firestore.DocumentRef{ID: "1"}}
+	for _, tt := range tests {
+		stmt, err := sqlparser.Parse("select * from users where " + tt.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		compiler := &exprCompiler{}
+		eval, err := compiler.compile(stmt.(*sqlparser.Select).Where.Expr)
+		if err != nil {
+			t.Errorf("compile(%s): %v", tt.expr, err)
+			continue
+		}
+		actual, err := eval(document, data, &evalEnv{binds: binds})
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+		} else if actual != tt.expected || !cmp.Equal(compiler.fields, tt.fields) {
+			t.Errorf("%s: expected %v %v, actual %v %v", tt.expr, tt.expected, tt.fields, actual, compiler.fields)
+		}
+	}
+
+	for _, expr := range []string{"name and true", "name + 1", "-name"} {
+		stmt, err := sqlparser.Parse("select * from users where " + expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		eval, err := (&exprCompiler{}).compile(stmt.(*sqlparser.Select).Where.Expr)
+		if err != nil {
+			t.Errorf("compile(%s): %v", expr, err)
+		} else if _, err := eval(document, data, nil); err == nil {
+			t.Errorf("%s: expected error", expr)
+		}
+	}
+}
+
+func TestExprColumn(t *testing.T) {
+	data := map[string]interface{}{
+		"name":  "Terry",
+		"email": "terry@psu.edu",
+		"tags":  []interface{}{"admin", "beta"},
+		"nick":  nil,
+		"born":  time.Date(2000, 2, 29, 22, 0, 0, 0, time.UTC),
+		"address": map[string]interface{}{
+			"city":  "Paris",
+			"lines": []interface{}{"1 Rue"},
+		},
+	}
+	tests := []struct {
+		expr     string
+		expected interface{}
+	}{
+		{expr: "upper(name)", expected: "TERRY"},
+		{expr: "substr(name, 2, 3)", expected: "err"},
+		{expr: "concat(lower(name), '-', length(tags))", expected: "terry-2"},
+		{expr: "regexp_extract(email, '@(.+)$')", expected: "psu.edu"},
+		{expr: "starts_with(email, 'terry') and ends_with(email, '.edu')", expected: true},
+		{expr: "length(split(email, '@'))", expected: float64(2)},
+		{expr: "upper(nick)", expected: nil},
+		{expr: "lpad(missing, 3)", expected: nil},
+		{expr: "extract(month from born at time zone 'Asia/Tokyo')", expected: float64(3)},
+		{expr: "format_timestamp('%F', born + interval 1 day)", expected: "2000-03-01"},
+		{expr: "timestamp_diff(now(), born, day) > 365", expected: true},
+		{expr: "tags[1]", expected: "beta"},
+		{expr: "tags[5]", expected: nil},
+		{expr: "address['city']", expected: "Paris"},
+		{expr: "upper(address.lines[0])", expected: "1 RUE"},
+		{expr: "array_join(array_slice(tags, 0, 1), ',')", expected: "admin"},
+		{expr: "array_contains(map_keys(address), 'city')", expected: true},
+		{expr: "case when length(tags) > 1 then 'many' when length(tags) = 1 then 'one' else 'none' end", expected: "many"},
+		{expr: "case upper(name) when 'TOM' then 1 when 'TERRY' then 2 end", expected: int64(2)},
+		{expr: "case when nick is null then null else 'x' end", expected: nil},
+		{expr: "case when missing > 1 then 'x' end", expected: nil},
+		{expr: "if(nick is not null, nick, name)", expected: "Terry"},
+		{expr: "if(missing, 1, upper(missing))", expected: nil},
+		{expr: "coalesce(nick, missing, email)", expected: "terry@psu.edu"},
+		{expr: "ifnull(nick, 'n/a')", expected: "n/a"},
+		{expr: "nullif(name, 'Terry')", expected: nil},
+		{expr: "cast(length(tags) as string)", expected: "2"},
+		{expr: "safe_cast(name as int)", expected: nil},
+		{expr: "typeof(name)", expected: "string"},
+		{expr: "typeof(cast('7' as int)) = 'integer'", expected: true},
+		{expr: "timestamp_diff(timestamp_add(born, interval 2 hour), date_trunc(born, day), hour)", expected: float64(24)},
+	}
+	for _, tt := range tests {
+		stmt, err := sqlparser.Parse(rewriteQuery("select " + tt.expr + " from users"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sel := New(&util.Context{}, "")
+		column, err := sel.exprColumn(stmt.(*sqlparser.Select).SelectExprs[0].(*sqlparser.AliasedExpr).Expr)
+		if err != nil {
+			t.Errorf("exprColumn(%s): %v", tt.expr, err)
+			continue
+		}
+		actual, err := readColumnValue(&firestore.DocumentSnapshot{Ref: &firestore.DocumentRef{ID: "1"}}, &data, column, nil)
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+		} else if !cmp.Equal(actual, tt.expected) {
+			t.Errorf("%s: expected %v, actual %v", tt.expr, tt.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package _select
+
+import (
+	"cloud.google.com/go/firestore"
+	"github.com/pgollangi/fireql/pkg/util"
+	"github.com/xwb1989/sqlparser"
+	"regexp"
+	"strings"
+)
+
+var simpleFieldRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z_0-9]*$`)
+
+// parseFieldPath parses field path in Firestore syntax, where segments are separated
+// by dots and segments with special characters are quoted in backticks,
+// e.g. address.`zip-code`. Backticks and backslashes in a quoted segment are
+// escaped by a backslash.
+func parseFieldPath(path string) (firestore.FieldPath, error) {
+	var fieldPath firestore.FieldPath
+	var segment strings.Builder
+	quoted := false
+	for i := 0; i < len(path); i++ {
+		ch := path[i]
+		switch {
+		case quoted && ch == '\\' && i+1 < len(path):
+			i++
+			segment.WriteByte(path[i])
+		case ch == '`':
+			quoted = !quoted
+		case ch == '.' && !quoted:
+			if segment.Len() == 0 {
+				return nil, util.NewParseError(util.CodeInvalidArgument, path, `invalid field path "%s", empty segment`, path)
+			}
+			fieldPath = append(fieldPath, segment.String())
+			segment.Reset()
+		default:
+			segment.WriteByte(ch)
+		}
+	}
+	if quoted {
+		return nil, util.NewParseError(util.CodeInvalidArgument, path, "invalid field path \"%s\", unclosed `", path)
+	}
+	if segment.Len() == 0 {
+		return nil, util.NewParseError(util.CodeInvalidArgument, path, `invalid field path "%s", empty segment`, path)
+	}
+	return append(fieldPath, segment.String()), nil
+}
+
+// fieldPathString formats the field path in Firestore syntax, quoting segments
+// with special characters in backticks.
+func fieldPathString(fieldPath firestore.FieldPath) string {
+	segments := make([]string, len(fieldPath))
+	for idx, segment := range fieldPath {
+		if simpleFieldRegex.MatchString(segment) {
+			segments[idx] = segment
+		} else {
+			segments[idx] = "`" + strings.NewReplacer("\\", "\\\\", "`", "\\`").Replace(segment) + "`"
+		}
+	}
+	return strings.Join(segments, ".")
+}
+
+// colFieldPath returns the field path referred by the column name.
+func colFieldPath(colName *sqlparser.ColName) (firestore.FieldPath, error) {
+	var fieldPath firestore.FieldPath
+	for _, part := range []string{colName.Qualifier.Qualifier.String(), colName.Qualifier.Name.String(), colName.Name.String()} {
+		if part == "" {
+			continue
+		}
+		partPath, err := parseFieldPath(part)
+		if err != nil {
+			return nil, err
+		}
+		fieldPath = append(fieldPath, partPath...)
+	}
+	return fieldPath, nil
+}
+
+// fieldPathColName returns the column name referring to the field path. Names of single
+// segments are kept as they are, other paths are named by their Firestore syntax.
+func fieldPathColName(fieldPath firestore.FieldPath) sqlparser.ColName {
+	if len(fieldPath) == 1 && !strings.ContainsAny(fieldPath[0], ".`") {
+		return sqlparser.ColName{Name: sqlparser.NewColIdent(fieldPath[0])}
+	}
+	return sqlparser.ColName{Name: sqlparser.NewColIdent(fieldPathString(fieldPath))}
+}
+
+// isDocumentID reports whether the field path refers to the document ID.
+func isDocumentID(fieldPath firestore.FieldPath) bool {
+	return len(fieldPath) == 1 && fieldPath[0] == firestore.DocumentID
+}
@@ -0,0 +1,73 @@
+package _select
+
+import (
+	"cloud.google.com/go/firestore"
+	"github.com/google/go-cmp/cmp"
+	"github.com/xwb1989/sqlparser"
+	"testing"
+)
+
+func TestParseFieldPath(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected firestore.FieldPath
+		wantErr  bool
+	}{
+		{path: "name", expected: firestore.FieldPath{"name"}},
+		{path: "address.city", expected: firestore.FieldPath{"address", "city"}},
+		{path: "address.`zip-code`", expected: firestore.FieldPath{"address", "zip-code"}},
+		{path: "`a.b`.`c\\`d`", expected: firestore.FieldPath{"a.b", "c`d"}},
+		{path: "a..b", wantErr: true},
+		{path: "a.`b", wantErr: true},
+	}
+	for _, tt := range tests {
+		actual, err := parseFieldPath(tt.path)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseFieldPath(%s): unexpected error %v", tt.path, err)
+			continue
+		}
+		if !cmp.Equal(actual, tt.expected) {
+			t.Errorf("parseFieldPath(%s): expected %v, actual %v", tt.path, tt.expected, actual)
+		}
+		if err == nil && fieldPathString(actual) != tt.path {
+			t.Errorf("fieldPathString(%v): expected %s, actual %s", actual, tt.path, fieldPathString(actual))
+		}
+	}
+}
+
+func TestColFieldPath(t *testing.T) {
+	stmt, err := sqlparser.Parse(rewriteQuery("select a.b.c.d, address.`zip-code`, `address.city`, x.`a.b`, users.name from users"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// quoted names are single segments, also on their own, and the collection qualifies its fields
+	unqualify(stmt, "users")
+	expected := []firestore.FieldPath{{"a", "b", "c", "d"}, {"address", "zip-code"}, {"address.city"}, {"x", "a.b"}, {"name"}}
+	for idx, selectExpr := range stmt.(*sqlparser.Select).SelectExprs {
+		actual, err := colFieldPath(selectExpr.(*sqlparser.AliasedExpr).Expr.(*sqlparser.ColName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !cmp.Equal(actual, expected[idx]) {
+			t.Errorf("colFieldPath: expected %v, actual %v", expected[idx], actual)
+		}
+	}
+
+	data := map[string]interface{}{"a.b": int64(1), "a": map[string]interface{}{"b": int64(2)}}
+	for _, tt := range []struct {
+		field    string
+		expected interface{}
+	}{{"`a.b`", int64(1)}, {"a.b", int64(2)}} {
+		stmt, err := sqlparser.Parse(rewriteQuery("select " + tt.field + " from users"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		path, err := colFieldPath(stmt.(*sqlparser.Select).SelectExprs[0].(*sqlparser.AliasedExpr).Expr.(*sqlparser.ColName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual, _ := lookupField(nil, data, path); actual != tt.expected {
+			t.Errorf("%s: expected %v, actual %v", tt.field, tt.expected, actual)
+		}
+	}
+}
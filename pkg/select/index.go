@@ -0,0 +1,73 @@
+package _select
+
+import (
+	"cloud.google.com/go/firestore"
+	"github.com/pgollangi/fireql/pkg/util"
+)
+
+// RequiredIndex returns the composite index the query needs, or nil when it doesn't need one.
+// The query isn't executed.
+func (sel *SelectStatement) RequiredIndex() (*util.Index, error) {
+	plan, err := sel.compile()
+	defer sel.closeClient()
+	if err != nil || plan.docRefs != nil {
+		return nil, err
+	}
+	if sel.join != nil {
+		// Right documents are read by a single field
+		return sel.join.left.requiredIndex(), nil
+	}
+	return sel.requiredIndex(), nil
+}
+
+// requiredIndex returns the composite index the compiled query needs, or nil when
+// single-field indexes Firestore creates automatically suffice. Queries need a composite
+// index when they order, or filter by a range, on a field and filter or order on other fields.
+// Equality fields come first in the index, followed by order and range fields.
+func (sel *SelectStatement) requiredIndex() *util.Index {
+	var fields []util.IndexField
+	seen := map[string]bool{}
+	addField := func(path firestore.FieldPath, field util.IndexField) {
+		field.FieldPath = fieldPathString(path)
+		if !isDocumentID(path) && !seen[field.FieldPath] {
+			seen[field.FieldPath] = true
+			fields = append(fields, field)
+		}
+	}
+
+	for _, filter := range sel.filters {
+		switch {
+		case isArrayContainsOp(filter.op):
+			addField(filter.path, util.IndexField{ArrayConfig: util.ArrayConfigContains})
+		case !isInequalityOp(filter.op):
+			addField(filter.path, util.IndexField{Order: util.OrderAscending})
+		}
+	}
+	equalityFields := len(fields)
+
+	orders := sel.queryOrders
+	if len(orders) == 0 && sel.inequalityField != nil {
+		orders = []queryOrder{{path: sel.inequalityField, direction: firestore.Asc}}
+	}
+	for _, order := range orders {
+		field := util.IndexField{Order: util.OrderAscending}
+		if order.direction == firestore.Desc {
+			field.Order = util.OrderDescending
+		}
+		addField(order.path, field)
+	}
+	for _, filter := range sel.filters {
+		if isInequalityOp(filter.op) {
+			addField(filter.path, util.IndexField{Order: util.OrderAscending})
+		}
+	}
+
+	if len(fields) == equalityFields || len(fields) < 2 {
+		return nil
+	}
+	scope := util.QueryScopeCollection
+	if sel.collection.Ref == nil {
+		scope = util.QueryScopeCollectionGroup
+	}
+	return &util.Index{CollectionGroup: sel.collection.ID, QueryScope: scope, Fields: fields}
+}
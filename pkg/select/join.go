@@ -0,0 +1,508 @@
+package _select
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/pgollangi/fireql/pkg/util"
+	"github.com/xwb1989/sqlparser"
+	"google.golang.org/api/iterator"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// joinBatchSize is the number of left documents joined at a time. Right documents of a batch
+// are read by a single GetAll or "in" query, which accepts at most maxDisjunctionValues values.
+const joinBatchSize = maxDisjunctionValues
+
+// joinPlan joins documents of the FROM collection, the left one, with documents of the JOIN collection,
+// the right one, having equal values of the key fields. Fields of joined documents are qualified
+// by aliases of their collections, e.g. u.name.
+type joinPlan struct {
+	leftName   string
+	leftAlias  string
+	leftKey    firestore.FieldPath
+	rightName  string
+	rightAlias string
+	rightKey   firestore.FieldPath
+	// LEFT JOIN keeps left documents without matching right documents
+	outer bool
+	// statement reading the left collection, with conditions and order on its fields only
+	left  *SelectStatement
+	right *util.Collection
+}
+
+// joinedRow is a document, or documents joined, with the data read from them.
+type joinedRow struct {
+	document *firestore.DocumentSnapshot
+	data     map[string]interface{}
+}
+
+// joinExpr returns the JOIN of the FROM clause, if any.
+func joinExpr(sQuery *sqlparser.Select) (*sqlparser.JoinTableExpr, bool) {
+	if len(sQuery.From) != 1 {
+		return nil, false
+	}
+	join, ok := sQuery.From[0].(*sqlparser.JoinTableExpr)
+	return join, ok
+}
+
+// newJoinPlan checks the JOIN is supported: INNER or LEFT JOIN of two collections
+// on equality of a field of each.
+func newJoinPlan(joinExpr *sqlparser.JoinTableExpr) (*joinPlan, error) {
+	join := &joinPlan{}
+	switch joinExpr.Join {
+	case sqlparser.JoinStr:
+	case sqlparser.LeftJoinStr:
+		join.outer = true
+	default:
+		return nil, util.NewUnsupportedError(util.CodeUnsupportedClause, strings.ToUpper(joinExpr.Join), "%s is not supported, only JOIN and LEFT JOIN", strings.ToUpper(joinExpr.Join))
+	}
+	var err error
+	if join.leftName, join.leftAlias, err = joinTable(joinExpr.LeftExpr); err != nil {
+		return nil, err
+	}
+	if join.rightName, join.rightAlias, err = joinTable(joinExpr.RightExpr); err != nil {
+		return nil, err
+	}
+	if join.leftAlias == join.rightAlias {
+		return nil, util.NewParseError(util.CodeInvalidArgument, join.rightAlias, `joined collections must have different aliases, got "%s" for both`, join.rightAlias)
+	}
+
+	condition := sqlparser.String(joinExpr.Condition.On)
+	if joinExpr.Condition.On == nil {
+		condition = sqlparser.String(joinExpr)
+	}
+	comparison, ok := joinExpr.Condition.On.(*sqlparser.ComparisonExpr)
+	if ok && comparison.Operator == sqlparser.EqualStr {
+		leftCol, leftOk := comparison.Left.(*sqlparser.ColName)
+		rightCol, rightOk := comparison.Right.(*sqlparser.ColName)
+		if leftOk && rightOk {
+			if join.leftKey, join.rightKey, err = join.keys(leftCol, rightCol); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if join.leftKey == nil || join.rightKey == nil {
+		return nil, util.NewUnsupportedError(util.CodeUnsupportedExpression, condition, "JOIN condition must compare a field of each collection for equality, e.g. o.user = u.__name__, got %s", condition)
+	}
+	return join, nil
+}
+
+// joinTable returns name and alias of the joined collection, the alias defaulting to the name.
+func joinTable(tableExpr sqlparser.TableExpr) (string, string, error) {
+	if aliasedExpr, ok := tableExpr.(*sqlparser.AliasedTableExpr); ok {
+		if tableName, ok := aliasedExpr.Expr.(sqlparser.TableName); ok {
+			alias := aliasedExpr.As.String()
+			if alias == "" {
+				alias = tableName.Name.String()
+			}
+			return tableName.Name.String(), alias, nil
+		}
+	}
+	if _, ok := tableExpr.(*sqlparser.JoinTableExpr); ok {
+		return "", "", util.NewUnsupportedError(util.CodeUnsupportedClause, sqlparser.String(tableExpr), "only two collections can be joined")
+	}
+	return "", "", util.NewUnsupportedError(util.CodeUnsupportedClause, sqlparser.String(tableExpr), "unsupported FROM clause: %s", sqlparser.String(tableExpr))
+}
+
+// keys returns the key fields of the left and the right collection compared by the JOIN condition,
+// nil when it doesn't compare a field of each.
+func (join *joinPlan) keys(leftCol *sqlparser.ColName, rightCol *sqlparser.ColName) (firestore.FieldPath, firestore.FieldPath, error) {
+	leftPath, err := colFieldPath(leftCol)
+	if err != nil {
+		return nil, nil, err
+	}
+	rightPath, err := colFieldPath(rightCol)
+	if err != nil {
+		return nil, nil, err
+	}
+	if join.aliasOf(rightPath) == join.leftAlias {
+		leftPath, rightPath = rightPath, leftPath
+	}
+	if join.aliasOf(leftPath) != join.leftAlias || join.aliasOf(rightPath) != join.rightAlias {
+		return nil, nil, nil
+	}
+	return leftPath[1:], rightPath[1:], nil
+}
+
+// aliasOf returns the collection alias qualifying the field path, or empty string if it isn't qualified.
+func (join *joinPlan) aliasOf(path firestore.FieldPath) string {
+	if len(path) > 1 && (path[0] == join.leftAlias || path[0] == join.rightAlias) {
+		return path[0]
+	}
+	return ""
+}
+
+// compileJoin compiles the query joining collections. Conditions and order on fields of the left
+// collection only are added to its Firestore query, other conditions and order, and LIMIT,
+// apply to joined documents on the client side.
+func (sel *SelectStatement) compileJoin(plan *queryPlan, sQuery *sqlparser.Select) error {
+	join := sel.join
+	if sel.pageToken != "" || sel.pageSize > 0 {
+		return util.NewUnsupportedError(util.CodeUnsupportedClause, "JOIN", "pagination isn't supported with JOIN")
+	}
+	if err := join.checkQualified(sQuery); err != nil {
+		return err
+	}
+	var err error
+	if join.right, err = util.ResolveCollection(sel.fireClient, join.rightName); err != nil {
+		return err
+	}
+	join.left = &SelectStatement{context: sel.context, fireClient: sel.fireClient, collection: sel.collection}
+
+	fQuery := sel.collection.Query
+	if sQuery.Where != nil {
+		if sQuery.Where.Type != sqlparser.WhereStr {
+			return util.NewUnsupportedError(util.CodeUnsupportedClause, sQuery.Where.Type, "unsupported WHERE type: %s", sQuery.Where.Type)
+		}
+		for _, condition := range splitAnd(sQuery.Where.Expr) {
+			if join.refersTo(condition, join.leftAlias) {
+				unqualify(condition, join.leftAlias)
+				fQuery, err = join.left.addWhereExpr(fQuery, sQuery, condition)
+			} else {
+				err = sel.addClientFilter(condition)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if plan.columns, err = sel.collectSelectColumns(sQuery.SelectExprs); err != nil {
+		return err
+	}
+	var columns []*selectColumn
+	for _, column := range plan.columns {
+		if column.colType == Star && column.path == nil {
+			// SELECT * selects fields of both collections
+			columns = append(columns,
+				&selectColumn{field: "*", path: firestore.FieldPath{join.leftAlias}, colType: Star},
+				&selectColumn{field: "*", path: firestore.FieldPath{join.rightAlias}, colType: Star})
+		} else {
+			columns = append(columns, column)
+		}
+	}
+	plan.columns = columns
+
+	leftOrder := len(sQuery.OrderBy) > 0
+	for _, order := range sQuery.OrderBy {
+		colName, ok := order.Expr.(*sqlparser.ColName)
+		leftOrder = leftOrder && ok && join.refersTo(colName, join.leftAlias)
+	}
+	if leftOrder {
+		// Joined documents keep the order of left documents
+		for _, order := range sQuery.OrderBy {
+			unqualify(order.Expr, join.leftAlias)
+		}
+		fQuery, err = join.left.addOrderBy(fQuery, &sqlparser.Select{OrderBy: sQuery.OrderBy}, nil)
+	} else {
+		_, err = sel.addOrderBy(fQuery, sQuery, plan.columns)
+	}
+	if err != nil {
+		return err
+	}
+
+	rows := sel.context.DefaultLimit
+	if sQuery.Limit != nil {
+		if rows, err = sel.limitRows(sQuery.Limit); err != nil {
+			return err
+		}
+	}
+	sel.rowLimit, sel.clientLimit = rows, rows
+	if rows > 0 && join.outer && len(sel.clientFilters) == 0 && len(sel.clientOrder) == 0 {
+		// Each left document is joined at least once
+		join.left.rowLimit = rows
+		fQuery = join.left.limit(fQuery, rows)
+	}
+	plan.query = fQuery
+	return nil
+}
+
+// checkQualified checks that fields are qualified by aliases of their collections, as either
+// collection could have the field otherwise. ORDER BY can refer to SELECT aliases too.
+func (join *joinPlan) checkQualified(sQuery *sqlparser.Select) error {
+	selectAliases := map[string]bool{}
+	for _, selectExpr := range sQuery.SelectExprs {
+		if aliasedExpr, ok := selectExpr.(*sqlparser.AliasedExpr); ok && !aliasedExpr.As.IsEmpty() {
+			selectAliases[aliasedExpr.As.String()] = true
+		}
+	}
+	check := func(node sqlparser.SQLNode, selectAliases map[string]bool) error {
+		return sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+			switch node := node.(type) {
+			case *sqlparser.ColName:
+				path, err := colFieldPath(node)
+				if err != nil {
+					return false, err
+				}
+				if join.aliasOf(path) == "" && !(len(path) == 1 && selectAliases[path[0]]) {
+					return false, util.NewParseError(util.CodeInvalidArgument, sqlparser.String(node),
+						`field "%s" must be qualified by alias of its collection, "%s" or "%s"`, fieldPathString(path), join.leftAlias, join.rightAlias)
+				}
+			case *sqlparser.StarExpr:
+				if alias := node.TableName.Name.String(); alias != "" && alias != join.leftAlias && alias != join.rightAlias {
+					return false, util.NewParseError(util.CodeInvalidArgument, sqlparser.String(node),
+						`unknown collection alias "%s", expected "%s" or "%s"`, alias, join.leftAlias, join.rightAlias)
+				}
+			}
+			return true, nil
+		}, node)
+	}
+	if err := check(sQuery.SelectExprs, nil); err != nil {
+		return err
+	}
+	if sQuery.Where != nil {
+		if err := check(sQuery.Where.Expr, nil); err != nil {
+			return err
+		}
+	}
+	return check(sQuery.OrderBy, selectAliases)
+}
+
+// refersTo reports whether the expression refers to fields, and only to fields, of the aliased collection.
+func (join *joinPlan) refersTo(expr sqlparser.Expr, alias string) bool {
+	found, other := false, false
+	_ = sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		if colName, ok := node.(*sqlparser.ColName); ok {
+			path, err := colFieldPath(colName)
+			if err == nil && join.aliasOf(path) == alias {
+				found = true
+			} else {
+				other = true
+			}
+		}
+		return !other, nil
+	}, expr)
+	return found && !other
+}
+
+// splitAnd returns conditions of the expression joined by AND.
+func splitAnd(expr sqlparser.Expr) []sqlparser.Expr {
+	switch expr := expr.(type) {
+	case *sqlparser.AndExpr:
+		return append(splitAnd(expr.Left), splitAnd(expr.Right)...)
+	case *sqlparser.ParenExpr:
+		if _, ok := expr.Expr.(*sqlparser.AndExpr); ok {
+			return splitAnd(expr.Expr)
+		}
+	}
+	return []sqlparser.Expr{expr}
+}
+
+// unqualify removes the collection alias from columns qualified by it, e.g. u.name into name.
+func unqualify(expr sqlparser.SQLNode, alias string) {
+	_ = sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		if colName, ok := node.(*sqlparser.ColName); ok {
+			if path, err := colFieldPath(colName); err == nil && len(path) > 1 && path[0] == alias {
+				*colName = fieldPathColName(path[1:])
+			}
+		}
+		return true, nil
+	}, expr)
+}
+
+// describe describes how documents are joined, e.g. for EXPLAIN.
+func (join *joinPlan) describe() string {
+	joinType := "JOIN"
+	if join.outer {
+		joinType = "LEFT JOIN"
+	}
+	read := fmt.Sprintf(`"in" queries on %s, %d values each`, fieldPathString(join.rightKey), joinBatchSize)
+	if isDocumentID(join.rightKey) {
+		read = fmt.Sprintf("GetAll of %d documents each", joinBatchSize)
+	}
+	return fmt.Sprintf("%s %s AS %s ON %s.%s = %s.%s by %s", joinType, join.rightName, join.rightAlias,
+		join.leftAlias, fieldPathString(join.leftKey), join.rightAlias, fieldPathString(join.rightKey), read)
+}
+
+// joinIterator joins left documents, in batches, with right documents read for their keys.
+type joinIterator struct {
+	sel  *SelectStatement
+	join *joinPlan
+	docs documentIterator
+	env  *evalEnv
+	// left documents read, to stop at the limit of the left query applied on the client side
+	read int
+	done bool
+	rows []joinedRow
+}
+
+func (it *joinIterator) Next() (*firestore.DocumentSnapshot, map[string]interface{}, error) {
+	for len(it.rows) == 0 {
+		if it.done {
+			return nil, nil, iterator.Done
+		}
+		if err := it.joinBatch(); err != nil {
+			return nil, nil, err
+		}
+	}
+	row := it.rows[0]
+	it.rows = it.rows[1:]
+	return row.document, row.data, nil
+}
+
+// joinBatch reads the next batch of left documents and joins them with right documents.
+func (it *joinIterator) joinBatch() error {
+	join := it.join
+	var lefts []joinedRow
+	var keys []interface{}
+	for len(lefts) < joinBatchSize && !it.done {
+		document, err := it.docs.Next()
+		if errors.Is(err, iterator.Done) {
+			it.done = true
+			break
+		} else if err != nil {
+			return err
+		}
+		data := document.Data()
+		matched, err := join.left.matchClientFilters(document, &data, it.env)
+		if err != nil {
+			return &filterError{err}
+		}
+		if !matched {
+			continue
+		}
+		lefts = append(lefts, joinedRow{document: document, data: data})
+		keys = append(keys, it.leftKey(document, data))
+		it.read++
+		if join.left.clientLimit > 0 && it.read == join.left.clientLimit {
+			it.done = true
+		}
+	}
+
+	rights, err := it.readRight(keys)
+	if err != nil {
+		return err
+	}
+	for idx, left := range lefts {
+		left.data[firestore.DocumentID] = left.document.Ref.ID
+		key, ok := joinKey(keys[idx])
+		matches := rights[key]
+		if !ok || len(matches) == 0 {
+			if join.outer {
+				it.rows = append(it.rows, joinedRow{document: left.document, data: map[string]interface{}{join.leftAlias: left.data, join.rightAlias: nil}})
+			}
+			continue
+		}
+		for _, right := range matches {
+			it.rows = append(it.rows, joinedRow{document: left.document, data: map[string]interface{}{join.leftAlias: left.data, join.rightAlias: right.data}})
+		}
+	}
+	return nil
+}
+
+// leftKey returns the join key of the left document, a reference of a right document when joining
+// on __name__ of right documents, nil when it doesn't match any.
+func (it *joinIterator) leftKey(document *firestore.DocumentSnapshot, data map[string]interface{}) interface{} {
+	val, _ := lookupField(document, data, it.join.leftKey)
+	if isDocumentID(it.join.leftKey) {
+		val = document.Ref
+	}
+	if !isDocumentID(it.join.rightKey) {
+		return val
+	}
+	ref, ok := val.(*firestore.DocumentRef)
+	if id, isString := val.(string); isString {
+		var err error
+		ref, err = it.join.right.DocumentRef(it.sel.fireClient, id)
+		ok = err == nil
+	}
+	if !ok || !it.join.right.Contains(ref) {
+		return nil
+	}
+	return ref
+}
+
+// readRight reads right documents matching the keys, by their join keys.
+func (it *joinIterator) readRight(keys []interface{}) (map[string][]joinedRow, error) {
+	var values []interface{}
+	seen := map[string]bool{}
+	for _, val := range keys {
+		if key, ok := joinKey(val); ok && !seen[key] {
+			seen[key] = true
+			values = append(values, val)
+		}
+	}
+	rights := map[string][]joinedRow{}
+	if len(values) == 0 {
+		return rights, nil
+	}
+
+	join := it.join
+	start := time.Now()
+	var documents []*firestore.DocumentSnapshot
+	if isDocumentID(join.rightKey) {
+		refs := make([]*firestore.DocumentRef, len(values))
+		for idx, val := range values {
+			refs[idx] = val.(*firestore.DocumentRef)
+		}
+		snapshots, err := it.sel.fireClient.GetAll(context.Background(), refs)
+		if err != nil {
+			return nil, err
+		}
+		for _, snapshot := range snapshots {
+			if snapshot.Exists() {
+				documents = append(documents, snapshot)
+			}
+		}
+		if it.sel.stats != nil {
+			// Looking up missing documents is a read as well
+			it.sel.stats.documentsRead += len(snapshots)
+		}
+	} else {
+		query := join.right.Query.WherePath(join.rightKey, "in", values)
+		if it.sel.stats != nil {
+			query = query.WithRunOptions(explainOptions)
+		}
+		queryDocs := query.Documents(context.Background())
+		var err error
+		documents, err = queryDocs.GetAll()
+		if err != nil {
+			return nil, err
+		}
+		if it.sel.stats != nil {
+			it.sel.stats.documentsRead += len(documents)
+			it.sel.stats.addQuery(queryDocs)
+		}
+	}
+	if it.sel.stats != nil {
+		it.sel.stats.fetchTime += time.Since(start)
+	}
+
+	for _, document := range documents {
+		data := document.Data()
+		var val interface{} = document.Ref
+		if !isDocumentID(join.rightKey) {
+			val, _ = lookupField(document, data, join.rightKey)
+		}
+		if key, ok := joinKey(val); ok {
+			data[firestore.DocumentID] = document.Ref.ID
+			rights[key] = append(rights[key], joinedRow{document: document, data: data})
+		}
+	}
+	return rights, nil
+}
+
+// joinKey returns a key equal for equal values, the way Firestore compares them, e.g. integer 1
+// and double 1.0 are equal. NULL and values other than strings, numbers, booleans, timestamps and
+// references don't join.
+func joinKey(val interface{}) (string, bool) {
+	switch val := val.(type) {
+	case string:
+		return "s" + val, true
+	case bool:
+		return "b" + strconv.FormatBool(val), true
+	case time.Time:
+		return "t" + strconv.FormatInt(val.UnixNano(), 10), true
+	case *firestore.DocumentRef:
+		return "r" + val.Path, true
+	}
+	if num, ok := numberValue(val); ok && !math.IsNaN(num) {
+		return "n" + strconv.FormatFloat(num, 'g', -1, 64), true
+	}
+	return "", false
+}
@@ -0,0 +1,164 @@
+package _select
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"errors"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pgollangi/fireql/pkg/util"
+	"github.com/xwb1989/sqlparser"
+	"testing"
+)
+
+func TestNewJoinPlan(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected *joinPlan
+		code     util.ErrorCode
+	}{
+		{
+			query: "select o.item, u.name from orders o join users as u on u.__name__ = o.user where o.status = 'paid' order by u.name",
+			expected: &joinPlan{leftName: "orders", leftAlias: "o", leftKey: firestore.FieldPath{"user"},
+				rightName: "users", rightAlias: "u", rightKey: firestore.FieldPath{firestore.DocumentID}},
+		},
+		{
+			query: "select * from users left join `users/1/orders` o on users.address.city = o.shipping.city",
+			expected: &joinPlan{leftName: "users", leftAlias: "users", leftKey: firestore.FieldPath{"address", "city"},
+				rightName: "users/1/orders", rightAlias: "o", rightKey: firestore.FieldPath{"shipping", "city"}, outer: true},
+		},
+		{query: "select * from orders o right join users u on o.user = u.id", code: util.CodeUnsupportedClause},
+		{query: "select * from orders o join users u on o.user = u.id join tags t on t.id = o.tag", code: util.CodeUnsupportedClause},
+		{query: "select * from orders o join users o on o.user = o.id", code: util.CodeInvalidArgument},
+		{query: "select * from orders o join users u on o.user > u.id", code: util.CodeUnsupportedExpression},
+		{query: "select * from orders o join users u on o.user = o.id", code: util.CodeUnsupportedExpression},
+		{query: "select * from orders o join users u using (id)", code: util.CodeUnsupportedExpression},
+		{query: "select o.item from orders o join users u on o.user = u.id where status = 'paid'", code: util.CodeInvalidArgument},
+		{query: "select x.* from orders o join users u on o.user = u.id", code: util.CodeInvalidArgument},
+	}
+	for _, tt := range tests {
+		stmt, err := sqlparser.Parse(rewriteQuery(tt.query))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sQuery := stmt.(*sqlparser.Select)
+		joinTableExpr, _ := joinExpr(sQuery)
+		join, err := newJoinPlan(joinTableExpr)
+		if err == nil {
+			err = join.checkQualified(sQuery)
+		}
+		if tt.expected != nil {
+			if err != nil {
+				t.Errorf("%s: %v", tt.query, err)
+			} else if !cmp.Equal(join, tt.expected, cmp.AllowUnexported(joinPlan{})) {
+				t.Errorf("%s: expected %+v, actual %+v", tt.query, tt.expected, join)
+			}
+			continue
+		}
+		var parseErr *util.ParseError
+		var unsupportedErr *util.UnsupportedError
+		switch {
+		case errors.As(err, &parseErr) && parseErr.Code == tt.code:
+		case errors.As(err, &unsupportedErr) && unsupportedErr.Code == tt.code:
+		default:
+			t.Errorf("%s: expected error with code %s, actual %v", tt.query, tt.code, err)
+		}
+	}
+}
+
+func TestUnqualify(t *testing.T) {
+	stmt, err := sqlparser.Parse(rewriteQuery("select * from orders o join users u on o.user = u.id where o.status = 'paid' and (o.`total-price` > 10 and u.age > o.min_age)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sQuery := stmt.(*sqlparser.Select)
+	join := &joinPlan{leftAlias: "o", rightAlias: "u"}
+	var left, other []string
+	for _, condition := range splitAnd(sQuery.Where.Expr) {
+		if join.refersTo(condition, join.leftAlias) {
+			unqualify(condition, join.leftAlias)
+			left = append(left, sqlparser.String(condition))
+		} else {
+			other = append(other, sqlparser.String(condition))
+		}
+	}
+	if expected := []string{"`status` = 'paid'", "`total-price` > 10"}; !cmp.Equal(left, expected) {
+		t.Errorf("expected left conditions %v, actual %v", expected, left)
+	}
+	if expected := []string{"`u.age` > `o.min_age`"}; !cmp.Equal(other, expected) {
+		t.Errorf("expected other conditions %v, actual %v", expected, other)
+	}
+
+	// unqualified columns refer to the same fields
+	for _, tt := range []struct {
+		column   string
+		expected string
+		path     firestore.FieldPath
+	}{
+		{column: "o.name", expected: "name", path: firestore.FieldPath{"name"}},
+		{column: "o.`total-price`", expected: "`total-price`", path: firestore.FieldPath{"total-price"}},
+		{column: "o.`a.b`", expected: "```a.b```", path: firestore.FieldPath{"a.b"}},
+		{column: "o.address.`zip-code`", expected: "`address.``zip-code```", path: firestore.FieldPath{"address", "zip-code"}},
+	} {
+		stmt, err := sqlparser.Parse(rewriteQuery("select " + tt.column + " from orders o"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		colName := stmt.(*sqlparser.Select).SelectExprs[0].(*sqlparser.AliasedExpr).Expr.(*sqlparser.ColName)
+		unqualify(colName, "o")
+		if actual := sqlparser.String(colName); actual != tt.expected {
+			t.Errorf("%s: expected %s, actual %s", tt.column, tt.expected, actual)
+		}
+		if path, err := colFieldPath(colName); err != nil || !cmp.Equal(path, tt.path) {
+			t.Errorf("%s: expected path %v, actual %v, %v", tt.column, tt.path, path, err)
+		}
+	}
+}
+
+func TestJoinKeys(t *testing.T) {
+	t.Setenv(FirestoreEmulatorHost, "localhost:8765")
+	client := newFirestoreTestClient(context.Background())
+	defer client.Close()
+	users, err := util.ResolveCollection(client, "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	it := &joinIterator{
+		sel:  &SelectStatement{fireClient: client},
+		join: &joinPlan{leftKey: firestore.FieldPath{"author"}, rightKey: firestore.FieldPath{firestore.DocumentID}, right: users},
+	}
+	tests := []struct {
+		author   interface{}
+		expected interface{}
+	}{
+		{author: client.Doc("users/1"), expected: "rusers/1"},
+		{author: "2", expected: "rusers/2"},
+		{author: "users/3", expected: "rusers/3"},
+		{author: client.Doc("posts/1"), expected: nil},
+		{author: int64(1), expected: nil},
+		{author: nil, expected: nil},
+	}
+	for _, tt := range tests {
+		var actual interface{}
+		if key, ok := joinKey(it.leftKey(nil, map[string]interface{}{"author": tt.author})); ok {
+			actual = key[:1] + documentPath(&firestore.DocumentRef{Path: key[1:]})
+		}
+		if actual != tt.expected {
+			t.Errorf("join key of %v: expected %v, actual %v", tt.author, tt.expected, actual)
+		}
+	}
+
+	for _, values := range [][]interface{}{{int64(1), 1.0}, {"a", "a"}, {client.Doc("users/1"), client.Doc("users/1")}} {
+		left, leftOk := joinKey(values[0])
+		right, rightOk := joinKey(values[1])
+		if !leftOk || !rightOk || left != right {
+			t.Errorf("expected %v and %v to join", values[0], values[1])
+		}
+	}
+	for _, values := range [][]interface{}{{int64(1), "1"}, {"users/1", client.Doc("users/1")}, {nil, nil}, {[]interface{}{1}, []interface{}{1}}} {
+		left, leftOk := joinKey(values[0])
+		right, rightOk := joinKey(values[1])
+		if leftOk && rightOk && left == right {
+			t.Errorf("expected %v and %v not to join", values[0], values[1])
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package _select
+
+import (
+	"bytes"
+	"cloud.google.com/go/firestore"
+	"fmt"
+	"github.com/pgollangi/fireql/pkg/support"
+	"github.com/pgollangi/fireql/pkg/util"
+	"github.com/xwb1989/sqlparser"
+	"google.golang.org/genproto/googleapis/type/latlng"
+	"sort"
+	"strings"
+	"time"
+)
+
+// orderColumn is a column results are sorted by on the client side.
+type orderColumn struct {
+	column     *selectColumn
+	desc       bool
+	nullsFirst bool
+}
+
+// addOrderBy orders the query by fields in ORDER BY clause. When ordering by an
+// expression, or by a SELECT alias of one, or with NULLS FIRST/LAST Firestore can't
+// honor, or joined documents, all results are sorted on the client side instead.
+func (sel *SelectStatement) addOrderBy(fQuery firestore.Query, sQuery *sqlparser.Select, columns []*selectColumn) (firestore.Query, error) {
+	var orders []*orderColumn
+	// joined documents are sorted on the client side
+	clientSide := sel.join != nil
+	for _, sOrder := range sQuery.OrderBy {
+		expr := sOrder.Expr
+		desc := sOrder.Direction == sqlparser.DescScr
+		// Firestore orders NULL before all other values
+		nullsFirst := !desc
+		if nullsFunc, ok := expr.(*sqlparser.FuncExpr); ok && len(nullsFunc.Exprs) == 1 &&
+			(nullsFunc.Name.Lowered() == "nulls_first" || nullsFunc.Name.Lowered() == "nulls_last") {
+			arg, ok := nullsFunc.Exprs[0].(*sqlparser.AliasedExpr)
+			if !ok {
+				return fQuery, util.NewUnsupportedError(util.CodeUnsupportedExpression, sqlparser.String(nullsFunc.Exprs[0]), "unsupported ORDER BY expression: %s", sqlparser.String(nullsFunc.Exprs[0]))
+			}
+			expr = arg.Expr
+			if requested := nullsFunc.Name.Lowered() == "nulls_first"; requested != nullsFirst {
+				nullsFirst = requested
+				clientSide = true
+			}
+		}
+		column, err := sel.orderByColumn(expr, columns)
+		if err != nil {
+			return fQuery, err
+		}
+		if column.colType != Field {
+			clientSide = true
+		}
+		orders = append(orders, &orderColumn{column: column, desc: desc, nullsFirst: nullsFirst})
+	}
+
+	if clientSide {
+		sel.clientOrder = orders
+		return fQuery, nil
+	}
+	for _, order := range orders {
+		direction := firestore.Asc
+		if order.desc {
+			direction = firestore.Desc
+		}
+		fQuery = fQuery.OrderByPath(order.column.path, direction)
+		sel.queryOrders = append(sel.queryOrders, queryOrder{path: order.column.path, direction: direction})
+	}
+	return fQuery, nil
+}
+
+// orderByColumn resolves the ORDER BY expression to a field, a selected column
+// by its alias or position, or an expression evaluated on each document.
+func (sel *SelectStatement) orderByColumn(expr sqlparser.Expr, columns []*selectColumn) (*selectColumn, error) {
+	switch expr := expr.(type) {
+	case *sqlparser.ColName:
+		fieldPath, err := colFieldPath(expr)
+		if err != nil {
+			return nil, err
+		}
+		if len(fieldPath) == 1 {
+			for _, column := range columns {
+				if column.colType != Star && column.alias == fieldPath[0] {
+					return column, nil
+				}
+			}
+		}
+		return newFieldColumn(fieldPath, ""), nil
+	case *sqlparser.SQLVal:
+		if expr.Type == sqlparser.IntVal {
+			var position int
+			if _, err := fmt.Sscan(string(expr.Val), &position); err != nil || position < 1 || position > len(columns) {
+				return nil, util.NewParseError(util.CodeInvalidArgument, string(expr.Val), "ORDER BY position %s is not in select list", string(expr.Val))
+			}
+			column := columns[position-1]
+			if column.colType == Star {
+				return nil, util.NewUnsupportedError(util.CodeUnsupportedExpression, string(expr.Val), "ORDER BY position %d refers to *", position)
+			}
+			return column, nil
+		}
+	}
+	return sel.exprColumn(expr)
+}
+
+// readOrderValues reads values of client side order columns from the document.
+// Values that can't be read, e.g. of missing fields, are NULL.
+func (sel *SelectStatement) readOrderValues(document *firestore.DocumentSnapshot, data *map[string]interface{}, env *evalEnv) []interface{} {
+	values := make([]interface{}, len(sel.clientOrder))
+	for idx, order := range sel.clientOrder {
+		val, err := readColumnValue(document, data, order.column, env)
+		if err == nil {
+			values[idx] = val
+		}
+	}
+	return values
+}
+
+// sortRows stably sorts rows by their order values.
+func (sel *SelectStatement) sortRows(rows [][]interface{}, orderValues [][]interface{}) [][]interface{} {
+	indexes := make([]int, len(rows))
+	for idx := range indexes {
+		indexes[idx] = idx
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		left, right := orderValues[indexes[i]], orderValues[indexes[j]]
+		for idx, order := range sel.clientOrder {
+			if cmp := order.compare(left[idx], right[idx]); cmp != 0 {
+				return cmp < 0
+			}
+		}
+		return false
+	})
+	sorted := make([][]interface{}, len(rows))
+	for idx, rowIdx := range indexes {
+		sorted[idx] = rows[rowIdx]
+	}
+	return sorted
+}
+
+func (order *orderColumn) compare(left interface{}, right interface{}) int {
+	switch {
+	case left == nil && right == nil:
+		return 0
+	case left == nil || right == nil:
+		if (left == nil) == order.nullsFirst {
+			return -1
+		}
+		return 1
+	}
+	cmp := compareValues(left, right)
+	if order.desc {
+		return -cmp
+	}
+	return cmp
+}
+
+// typeOrder is the order of values of different types in Firestore.
+// See https://firebase.google.com/docs/firestore/manage-data/data-types#value_type_ordering
+var typeOrder = map[string]int{
+	"null":      0,
+	"boolean":   1,
+	"integer":   2,
+	"double":    2,
+	"timestamp": 3,
+	"string":    4,
+	"bytes":     5,
+	"reference": 6,
+	"geopoint":  7,
+	"array":     8,
+	"map":       9,
+	"unknown":   10,
+}
+
+// compareValues compares values like Firestore orders them,
+// returning -1, 0 or 1 when left is less than, equal to or greater than right.
+func compareValues(left interface{}, right interface{}) int {
+	leftType, rightType := support.FirestoreType(left), support.FirestoreType(right)
+	if typeOrder[leftType] != typeOrder[rightType] {
+		return compareInts(typeOrder[leftType], typeOrder[rightType])
+	}
+	switch left := left.(type) {
+	case bool:
+		return compareInts(boolToInt(left), boolToInt(right.(bool)))
+	case string:
+		return strings.Compare(left, right.(string))
+	case []byte:
+		return bytes.Compare(left, right.([]byte))
+	case time.Time:
+		right := right.(time.Time)
+		switch {
+		case left.Before(right):
+			return -1
+		case left.After(right):
+			return 1
+		}
+		return 0
+	case *firestore.DocumentRef:
+		// Firestore orders references by path segments, e.g. a/b/c before a/b-c
+		leftSegments, rightSegments := strings.Split(left.Path, "/"), strings.Split(right.(*firestore.DocumentRef).Path, "/")
+		for idx := 0; idx < len(leftSegments) && idx < len(rightSegments); idx++ {
+			if cmp := strings.Compare(leftSegments[idx], rightSegments[idx]); cmp != 0 {
+				return cmp
+			}
+		}
+		return compareInts(len(leftSegments), len(rightSegments))
+	case *latlng.LatLng:
+		right := right.(*latlng.LatLng)
+		if cmp := compareFloats(left.GetLatitude(), right.GetLatitude()); cmp != 0 {
+			return cmp
+		}
+		return compareFloats(left.GetLongitude(), right.GetLongitude())
+	case []interface{}:
+		right := right.([]interface{})
+		for idx := 0; idx < len(left) && idx < len(right); idx++ {
+			if cmp := compareValues(left[idx], right[idx]); cmp != 0 {
+				return cmp
+			}
+		}
+		return compareInts(len(left), len(right))
+	case map[string]interface{}:
+		right := right.(map[string]interface{})
+		leftKeys, rightKeys := sortedKeys(left), sortedKeys(right)
+		for idx := 0; idx < len(leftKeys) && idx < len(rightKeys); idx++ {
+			if cmp := strings.Compare(leftKeys[idx], rightKeys[idx]); cmp != 0 {
+				return cmp
+			}
+			if cmp := compareValues(left[leftKeys[idx]], right[rightKeys[idx]]); cmp != 0 {
+				return cmp
+			}
+		}
+		return compareInts(len(leftKeys), len(rightKeys))
+	}
+	if leftNum, ok := numberValue(left); ok {
+		if rightNum, ok := numberValue(right); ok {
+			return compareFloats(leftNum, rightNum)
+		}
+	}
+	return strings.Compare(fmt.Sprint(left), fmt.Sprint(right))
+}
+
+func numberValue(val interface{}) (float64, bool) {
+	switch val := val.(type) {
+	case int:
+		return float64(val), true
+	case int32:
+		return float64(val), true
+	case int64:
+		return float64(val), true
+	case float32:
+		return float64(val), true
+	case float64:
+		return val, true
+	}
+	return 0, false
+}
+
+func compareInts(left int, right int) int {
+	switch {
+	case left < right:
+		return -1
+	case left > right:
+		return 1
+	}
+	return 0
+}
+
+func compareFloats(left float64, right float64) int {
+	switch {
+	case left < right:
+		return -1
+	case left > right:
+		return 1
+	}
+	return 0
+}
+
+func boolToInt(val bool) int {
+	if val {
+		return 1
+	}
+	return 0
+}
+
+func sortedKeys(data map[string]interface{}) []string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,52 @@
+package _select
+
+import (
+	"cloud.google.com/go/firestore"
+	"github.com/google/go-cmp/cmp"
+	"testing"
+	"time"
+)
+
+func TestCompareValues(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		left     interface{}
+		right    interface{}
+		expected int
+	}{
+		{left: int64(2), right: 2.5, expected: -1},
+		{left: "b", right: "a", expected: 1},
+		{left: true, right: int64(1), expected: -1},
+		{left: now, right: "a", expected: -1},
+		{left: now.Add(time.Second), right: now, expected: 1},
+		{left: []interface{}{"a", "b"}, right: []interface{}{"a"}, expected: 1},
+		{left: map[string]interface{}{"a": 1.0}, right: map[string]interface{}{"a": 1.0}, expected: 0},
+		{left: map[string]interface{}{"a": 1.0}, right: map[string]interface{}{"b": 0.0}, expected: -1},
+		{left: &firestore.DocumentRef{Path: "a/b-c/d/e"}, right: &firestore.DocumentRef{Path: "a/b/c/d"}, expected: 1},
+		{left: &firestore.DocumentRef{Path: "a/b"}, right: &firestore.DocumentRef{Path: "a/b/c/d"}, expected: -1},
+	}
+	for _, tt := range tests {
+		if actual := compareValues(tt.left, tt.right); actual != tt.expected {
+			t.Errorf("compareValues(%v, %v): expected %d, actual %d", tt.left, tt.right, tt.expected, actual)
+		}
+	}
+}
+
+func TestSortRows(t *testing.T) {
+	rows := [][]interface{}{{"a"}, {"b"}, {"c"}, {"d"}}
+	orderValues := [][]interface{}{{2.0}, {nil}, {1.0}, {2.0}}
+	tests := []struct {
+		order    orderColumn
+		expected [][]interface{}
+	}{
+		{order: orderColumn{nullsFirst: true}, expected: [][]interface{}{{"b"}, {"c"}, {"a"}, {"d"}}},
+		{order: orderColumn{desc: true}, expected: [][]interface{}{{"a"}, {"d"}, {"c"}, {"b"}}},
+		{order: orderColumn{desc: true, nullsFirst: true}, expected: [][]interface{}{{"b"}, {"a"}, {"d"}, {"c"}}},
+	}
+	for _, tt := range tests {
+		sel := &SelectStatement{clientOrder: []*orderColumn{&tt.order}}
+		if actual := sel.sortRows(rows, orderValues); !cmp.Equal(actual, tt.expected) {
+			t.Errorf("sortRows(%+v): expected %v, actual %v", tt.order, tt.expected, actual)
+		}
+	}
+}
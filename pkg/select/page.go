@@ -0,0 +1,277 @@
+package _select
+
+import (
+	"cloud.google.com/go/firestore"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/pgollangi/fireql/pkg/support"
+	"github.com/pgollangi/fireql/pkg/util"
+	"google.golang.org/genproto/googleapis/type/latlng"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// queryOrder is an order of the Firestore query.
+type queryOrder struct {
+	path      firestore.FieldPath
+	direction firestore.Direction
+}
+
+// cursorValue is a typed value of an order field in a page token.
+type cursorValue struct {
+	Type  string          `json:"t"`
+	Value json.RawMessage `json:"v,omitempty"`
+}
+
+// ExecutePage executes the query returning at most pageSize records, or LIMIT records when pageSize
+// is 0, starting after the position of the pageToken or from the first record when it's empty.
+func (sel *SelectStatement) ExecutePage(pageSize int, pageToken string) (*util.QueryResult, error) {
+	sel.pageSize = pageSize
+	sel.pageToken = pageToken
+	return sel.Execute()
+}
+
+// extractPageToken removes "AFTER 'token'" from the query, returning the query and the token.
+func extractPageToken(query string) (string, string) {
+	tokens := tokenize(query)
+	depth := 0
+	for i, t := range tokens {
+		switch {
+		case t.text == "(":
+			depth++
+		case t.text == ")":
+			depth--
+		case depth == 0 && t.isKeyword("after"):
+			next := nextToken(tokens, i)
+			if next < len(tokens) && tokens[next].kind == tokenString {
+				pageToken := unquoteString(tokens[next].text)
+				return joinTokens(append(append([]token{}, tokens[:i]...), tokens[next+1:]...)), pageToken
+			}
+		}
+	}
+	return query, ""
+}
+
+// addCursor orders the query by a unique combination of fields, the way Firestore does implicitly,
+// so that the next page can start after the last document of the page. Results sorted on the client
+// side can't be paged.
+func (sel *SelectStatement) addCursor(fQuery firestore.Query, limited bool) (firestore.Query, error) {
+	if len(sel.clientOrder) > 0 {
+		if sel.pageToken != "" {
+			return fQuery, util.NewUnsupportedError(util.CodeUnsupportedClause, "AFTER", "pagination isn't supported when results are sorted client-side")
+		}
+		return fQuery, nil
+	}
+	if !limited && sel.pageToken == "" {
+		return fQuery, nil
+	}
+
+	orders := sel.queryOrders
+	if len(orders) == 0 && sel.inequalityField != nil {
+		orders = append(orders, queryOrder{path: sel.inequalityField, direction: firestore.Asc})
+	}
+	orderedByID := false
+	for _, order := range orders {
+		orderedByID = orderedByID || isDocumentID(order.path)
+	}
+	if !orderedByID {
+		direction := firestore.Asc
+		if len(orders) > 0 {
+			direction = orders[len(orders)-1].direction
+		}
+		orders = append(orders, queryOrder{path: firestore.FieldPath{firestore.DocumentID}, direction: direction})
+	}
+	for _, order := range orders[len(sel.queryOrders):] {
+		fQuery = fQuery.OrderByPath(order.path, order.direction)
+	}
+	sel.cursorOrders = orders
+
+	if sel.pageToken != "" {
+		values, err := sel.decodePageToken(sel.pageToken)
+		if err != nil {
+			return fQuery, err
+		}
+		fQuery = fQuery.StartAfter(values...)
+	}
+	return fQuery, nil
+}
+
+// nextPageToken returns the token of the position after the document.
+func (sel *SelectStatement) nextPageToken(document *firestore.DocumentSnapshot) (string, error) {
+	values := make([]cursorValue, len(sel.cursorOrders))
+	for idx, order := range sel.cursorOrders {
+		var val interface{} = document.Ref
+		if !isDocumentID(order.path) {
+			var err error
+			if val, err = document.DataAtPath(order.path); err != nil {
+				return "", err
+			}
+		}
+		cursorVal, err := encodeCursorValue(val)
+		if err != nil {
+			return "", err
+		}
+		values[idx] = cursorVal
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodePageToken returns values of order fields encoded in the page token.
+func (sel *SelectStatement) decodePageToken(pageToken string) ([]interface{}, error) {
+	invalidToken := util.NewParseError(util.CodeInvalidArgument, pageToken, "invalid page token %s", pageToken)
+	data, err := base64.RawURLEncoding.DecodeString(pageToken)
+	if err != nil {
+		return nil, invalidToken
+	}
+	var cursorValues []cursorValue
+	if err = json.Unmarshal(data, &cursorValues); err != nil {
+		return nil, invalidToken
+	}
+	if len(cursorValues) != len(sel.cursorOrders) {
+		return nil, util.NewParseError(util.CodeInvalidArgument, pageToken, "page token doesn't match ORDER BY of the query")
+	}
+	values := make([]interface{}, len(cursorValues))
+	for idx, cursorVal := range cursorValues {
+		if values[idx], err = sel.decodeCursorValue(cursorVal); err != nil {
+			return nil, invalidToken
+		}
+	}
+	return values, nil
+}
+
+func encodeCursorValue(val interface{}) (cursorValue, error) {
+	var encoded interface{}
+	switch val := val.(type) {
+	case nil:
+		return cursorValue{Type: "null"}, nil
+	case int64:
+		encoded = strconv.FormatInt(val, 10)
+	case time.Time:
+		encoded = val.Format(time.RFC3339Nano)
+	case *firestore.DocumentRef:
+		encoded = documentPath(val)
+	case *latlng.LatLng:
+		encoded = []float64{val.GetLatitude(), val.GetLongitude()}
+	case []interface{}:
+		elements := make([]cursorValue, len(val))
+		for idx, element := range val {
+			var err error
+			if elements[idx], err = encodeCursorValue(element); err != nil {
+				return cursorValue{}, err
+			}
+		}
+		encoded = elements
+	case map[string]interface{}:
+		entries := map[string]cursorValue{}
+		for key, entry := range val {
+			var err error
+			if entries[key], err = encodeCursorValue(entry); err != nil {
+				return cursorValue{}, err
+			}
+		}
+		encoded = entries
+	default:
+		encoded = val
+	}
+	data, err := json.Marshal(encoded)
+	if err != nil {
+		return cursorValue{}, err
+	}
+	return cursorValue{Type: support.FirestoreType(val), Value: data}, nil
+}
+
+func (sel *SelectStatement) decodeCursorValue(cursorVal cursorValue) (interface{}, error) {
+	var err error
+	switch cursorVal.Type {
+	case "null":
+		return nil, nil
+	case "boolean":
+		var val bool
+		err = json.Unmarshal(cursorVal.Value, &val)
+		return val, err
+	case "integer":
+		var val string
+		if err = json.Unmarshal(cursorVal.Value, &val); err != nil {
+			return nil, err
+		}
+		return strconv.ParseInt(val, 10, 64)
+	case "double":
+		var val float64
+		err = json.Unmarshal(cursorVal.Value, &val)
+		return val, err
+	case "string":
+		var val string
+		err = json.Unmarshal(cursorVal.Value, &val)
+		return val, err
+	case "bytes":
+		var val []byte
+		err = json.Unmarshal(cursorVal.Value, &val)
+		return val, err
+	case "timestamp":
+		var val string
+		if err = json.Unmarshal(cursorVal.Value, &val); err != nil {
+			return nil, err
+		}
+		return time.Parse(time.RFC3339Nano, val)
+	case "reference":
+		var val string
+		if err = json.Unmarshal(cursorVal.Value, &val); err != nil {
+			return nil, err
+		}
+		ref := sel.fireClient.Doc(val)
+		if ref == nil {
+			return nil, fmt.Errorf("invalid document path %s", val)
+		}
+		return ref, nil
+	case "geopoint":
+		var val []float64
+		if err = json.Unmarshal(cursorVal.Value, &val); err != nil || len(val) != 2 {
+			return nil, fmt.Errorf("invalid geopoint %s", cursorVal.Value)
+		}
+		return &latlng.LatLng{Latitude: val[0], Longitude: val[1]}, nil
+	case "array":
+		var elements []cursorValue
+		if err = json.Unmarshal(cursorVal.Value, &elements); err != nil {
+			return nil, err
+		}
+		val := make([]interface{}, len(elements))
+		for idx, element := range elements {
+			if val[idx], err = sel.decodeCursorValue(element); err != nil {
+				return nil, err
+			}
+		}
+		return val, nil
+	case "map":
+		var entries map[string]cursorValue
+		if err = json.Unmarshal(cursorVal.Value, &entries); err != nil {
+			return nil, err
+		}
+		val := map[string]interface{}{}
+		for key, entry := range entries {
+			if val[key], err = sel.decodeCursorValue(entry); err != nil {
+				return nil, err
+			}
+		}
+		return val, nil
+	}
+	return nil, fmt.Errorf("unsupported value type %s", cursorVal.Type)
+}
+
+// documentPath returns path of the document relative to the database root, e.g. "users/abc".
+func documentPath(ref *firestore.DocumentRef) string {
+	return relativePath(ref.Path)
+}
+
+// relativePath returns the path of a document or collection relative to the database root.
+func relativePath(path string) string {
+	if idx := strings.Index(path, "/documents/"); idx >= 0 {
+		return path[idx+len("/documents/"):]
+	}
+	return path
+}
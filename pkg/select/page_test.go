@@ -0,0 +1,49 @@
+package _select
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"google.golang.org/genproto/googleapis/type/latlng"
+	"testing"
+	"time"
+)
+
+func TestExtractPageToken(t *testing.T) {
+	tests := []struct {
+		query     string
+		expected  string
+		pageToken string
+	}{
+		{query: "select * from users limit 10 after 'abc'", expected: "select * from users limit 10 ", pageToken: "abc"},
+		{query: "select * from users AFTER 'abc' limit 10", expected: "select * from users  limit 10", pageToken: "abc"},
+		{query: "select * from users where name = 'after'", expected: "select * from users where name = 'after'"},
+	}
+	for _, tt := range tests {
+		query, pageToken := extractPageToken(tt.query)
+		if query != tt.expected || pageToken != tt.pageToken {
+			t.Errorf("extractPageToken(%s): expected %q %q, actual %q %q", tt.query, tt.expected, tt.pageToken, query, pageToken)
+		}
+	}
+}
+
+func TestCursorValues(t *testing.T) {
+	sel := &SelectStatement{}
+	values := []interface{}{
+		nil, true, int64(1) << 60, 2.5, "abc", []byte("abc"),
+		time.Date(2024, 1, 1, 10, 0, 0, 123, time.UTC),
+		&latlng.LatLng{Latitude: 1, Longitude: 2},
+		[]interface{}{int64(1), "a"},
+		map[string]interface{}{"a": map[string]interface{}{"b": false}},
+	}
+	for _, val := range values {
+		encoded, err := encodeCursorValue(val)
+		if err != nil {
+			t.Errorf("encodeCursorValue(%v): %v", val, err)
+			continue
+		}
+		actual, err := sel.decodeCursorValue(encoded)
+		if err != nil || !cmp.Equal(val, actual, cmpopts.IgnoreUnexported(latlng.LatLng{})) {
+			t.Errorf("decodeCursorValue(%v): expected %v, actual %v, %v", encoded, val, actual, err)
+		}
+	}
+}
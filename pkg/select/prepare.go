@@ -0,0 +1,210 @@
+package _select
+
+import (
+	"cloud.google.com/go/firestore"
+	"database/sql"
+	"fmt"
+	"github.com/pgollangi/fireql/pkg/support"
+	"github.com/pgollangi/fireql/pkg/util"
+	"github.com/xwb1989/sqlparser"
+	"reflect"
+	"strconv"
+)
+
+// bindVars are values of query parameters by name. The parser names ? parameters v1, v2 and so on.
+type bindVars map[string]interface{}
+
+// boundValue is a filter value computed from query parameters or volatile functions on each execution,
+// e.g. of ?, LOWER(:name) or NOW() - INTERVAL 1 DAY.
+type boundValue struct {
+	expr string
+	eval evaluator
+}
+
+func (val *boundValue) String() string {
+	return val.expr
+}
+
+// Prepared is a SELECT query compiled once, to be executed many times
+// with different values of its parameters.
+type Prepared struct {
+	sel  *SelectStatement
+	plan *queryPlan
+}
+
+// Prepare compiles the query, which may have ? and :name parameters, without running it.
+// The prepared statement must be closed when it's no longer used.
+func (sel *SelectStatement) Prepare() (*Prepared, error) {
+	plan, err := sel.compile()
+	if err != nil {
+		sel.closeClient()
+		return nil, err
+	}
+	return &Prepared{sel: sel, plan: plan}, nil
+}
+
+// Execute runs the query with values of its parameters: ? parameters take args in order,
+// :name parameters take args given as sql.NamedArg, e.g. sql.Named("name", "abc").
+// It's safe to execute the statement concurrently.
+func (p *Prepared) Execute(args ...interface{}) (*util.QueryResult, error) {
+	return p.sel.run(p.plan, args)
+}
+
+// Close closes the Firestore client of the statement, unless it's shared.
+func (p *Prepared) Close() error {
+	return p.sel.closeClient()
+}
+
+// bind returns values of the query parameters from args, which must have a value of each parameter.
+func (plan *queryPlan) bind(args []interface{}) (bindVars, error) {
+	binds := bindVars{}
+	position := 0
+	for _, arg := range args {
+		name := ""
+		if named, ok := arg.(sql.NamedArg); ok {
+			name, arg = named.Name, named.Value
+		} else {
+			position++
+			name = "v" + strconv.Itoa(position)
+		}
+		if !containsString(plan.params, name) {
+			return nil, util.NewParseError(util.CodeInvalidArgument, ":"+name, "query has no parameter :%s, got %d args for %d parameters", name, len(args), len(plan.params))
+		}
+		binds[name] = bindValue(arg)
+	}
+	for _, name := range plan.params {
+		if _, ok := binds[name]; !ok {
+			return nil, util.NewParseError(util.CodeInvalidArgument, ":"+name, "missing value of parameter :%s", name)
+		}
+	}
+	return binds, nil
+}
+
+// bindValue converts Go numbers to Firestore integers and doubles, e.g. int32 to int64.
+func bindValue(val interface{}) interface{} {
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	}
+	return val
+}
+
+// bindQuery adds filters with values computed from the parameters, or volatile functions such as NOW(), to the query.
+func (sel *SelectStatement) bindQuery(fQuery firestore.Query, binds bindVars) (firestore.Query, error) {
+	for _, filter := range sel.filters {
+		if !hasBoundValue(filter.value) {
+			continue
+		}
+		val, err := resolveValue(filter.value, binds)
+		if err != nil {
+			return fQuery, err
+		}
+		if isDocumentID(filter.path) {
+			if val, err = sel.toDocumentRefs(val); err != nil {
+				return fQuery, err
+			}
+		}
+		fQuery = fQuery.WherePath(filter.path, filter.op, val)
+	}
+	return fQuery, nil
+}
+
+// boundValue compiles the value expression with parameters or volatile functions,
+// to be computed on each execution.
+func (sel *SelectStatement) boundValue(expr sqlparser.Expr) (*boundValue, error) {
+	compiler := &exprCompiler{functions: sel.context.Functions}
+	eval, err := compiler.compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(compiler.fields) > 0 || len(compiler.derefs) > 0 {
+		return nil, util.NewUnsupportedError(util.CodeUnsupportedExpression, sqlparser.String(expr), "unsupported value: %s", sqlparser.String(expr))
+	}
+	return &boundValue{expr: sqlparser.String(expr), eval: eval}, nil
+}
+
+// resolveValue computes bound values in the filter value.
+func resolveValue(val interface{}, binds bindVars) (interface{}, error) {
+	switch val := val.(type) {
+	case *boundValue:
+		resolved, err := val.eval(nil, nil, &evalEnv{binds: binds})
+		if err != nil {
+			return nil, fmt.Errorf("couldn't evaluate %s: %v", val.expr, err)
+		}
+		return resolved, nil
+	case []interface{}:
+		values := make([]interface{}, len(val))
+		for idx, element := range val {
+			resolved, err := resolveValue(element, binds)
+			if err != nil {
+				return nil, err
+			}
+			values[idx] = resolved
+		}
+		return values, nil
+	}
+	return val, nil
+}
+
+func hasBoundValue(val interface{}) bool {
+	switch val := val.(type) {
+	case *boundValue:
+		return true
+	case []interface{}:
+		for _, element := range val {
+			if hasBoundValue(element) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// bindVarNames returns names of parameters in the query, in order of their first occurrence.
+func bindVarNames(node sqlparser.SQLNode) []string {
+	var names []string
+	_ = sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		if val, ok := node.(*sqlparser.SQLVal); ok && val.Type == sqlparser.ValArg && !containsString(names, bindVarName(val)) {
+			names = append(names, bindVarName(val))
+		}
+		return true, nil
+	}, node)
+	return names
+}
+
+// hasBindVars reports whether the expression refers to any query parameter.
+func hasBindVars(expr sqlparser.Expr) bool {
+	return len(bindVarNames(expr)) > 0
+}
+
+// callsVolatile reports whether the expression calls a volatile function, e.g. NOW(),
+// which is evaluated on each execution, like parameters.
+func callsVolatile(expr sqlparser.Expr, functions *support.Functions) bool {
+	found := false
+	_ = sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		if funcExpr, ok := node.(*sqlparser.FuncExpr); ok {
+			found = functions.IsVolatile(funcExpr.Name.String())
+		}
+		return !found, nil
+	}, expr)
+	return found
+}
+
+// bindVarName returns the name of the parameter without the leading colon.
+func bindVarName(val *sqlparser.SQLVal) string {
+	return string(val.Val[1:])
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package _select
+
+import (
+	"database/sql"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pgollangi/fireql/pkg/support"
+	"github.com/pgollangi/fireql/pkg/util"
+	"github.com/xwb1989/sqlparser"
+	"testing"
+)
+
+func TestBindVars(t *testing.T) {
+	stmt, err := sqlparser.Parse(rewriteQuery("select * from users where age > ? and name in (:name, ?) and lower(city) = lower(:name)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan := &queryPlan{params: bindVarNames(stmt)}
+	if expected := []string{"v1", "name", "v2"}; !cmp.Equal(plan.params, expected) {
+		t.Errorf("expected params %v, actual %v", expected, plan.params)
+	}
+
+	binds, err := plan.bind([]interface{}{int32(18), sql.Named("name", "x"), 2.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (bindVars{"v1": int64(18), "name": "x", "v2": 2.5}); !cmp.Equal(binds, expected) {
+		t.Errorf("expected binds %v, actual %v", expected, binds)
+	}
+
+	for _, args := range [][]interface{}{
+		{18, sql.Named("name", "x")},
+		{18, sql.Named("name", "x"), 2, 3},
+		{18, sql.Named("nick", "x"), 2},
+	} {
+		if _, err := plan.bind(args); err == nil {
+			t.Errorf("bind(%v): expected error", args)
+		}
+	}
+}
+
+func TestBoundValue(t *testing.T) {
+	sel := New(&util.Context{}, "")
+	stmt, err := sqlparser.Parse("select * from users where age in (1, ?, :n + 1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	val, err := sel.getValueFromExpr(stmt.(*sqlparser.Select).Where.Expr.(*sqlparser.ComparisonExpr).Right)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasBoundValue(val) || explainValue(val) != "(1, :v1, :n + 1)" {
+		t.Errorf("expected bound value (1, :v1, :n + 1), actual %v", explainValue(val))
+	}
+	resolved, err := resolveValue(val, bindVars{"v1": int64(2), "n": int64(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []interface{}{1, int64(2), int64(3)}; !cmp.Equal(resolved, expected) {
+		t.Errorf("expected %v, actual %v", expected, resolved)
+	}
+}
+
+func TestVolatileBoundValue(t *testing.T) {
+	ticks := int64(0)
+	functions := support.NewFunctions()
+	if err := functions.RegisterVolatile("tick", func([]interface{}) (interface{}, error) {
+		ticks++
+		return ticks, nil
+	}, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := functions.Register("one", func([]interface{}) (interface{}, error) { return int64(1), nil }, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	sel := New(&util.Context{Functions: functions}, "")
+	stmt, err := sqlparser.Parse("select * from users where a = tick() + 1 and b = one()")
+	if err != nil {
+		t.Fatal(err)
+	}
+	where := stmt.(*sqlparser.Select).Where.Expr.(*sqlparser.AndExpr)
+	val, err := sel.getValueFromExpr(where.Left.(*sqlparser.ComparisonExpr).Right)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// volatile functions are called on each execution
+	for _, expected := range []int64{2, 3} {
+		resolved, err := resolveValue(val, nil)
+		if err != nil || resolved != expected {
+			t.Errorf("tick() + 1: expected %d, actual %v, %v", expected, resolved, err)
+		}
+	}
+	if val, err := sel.getValueFromExpr(where.Right.(*sqlparser.ComparisonExpr).Right); err != nil || hasBoundValue(val) || val != int64(1) {
+		t.Errorf("one(): expected constant 1, actual %v, %v", val, err)
+	}
+}
@@ -0,0 +1,751 @@
+package _select
+
+import (
+	"cloud.google.com/go/firestore"
+	"strings"
+)
+
+// The SQL parser only understands MySQL syntax. Before parsing, queries are
+// tokenized and FireQL specific syntax is rewritten into equivalent function
+// calls the parser accepts, e.g. TIMESTAMP '2024-01-01' to TIMESTAMP('2024-01-01').
+
+type tokenKind int
+
+const (
+	tokenSpace tokenKind = iota
+	tokenIdent
+	tokenQuotedIdent
+	tokenString
+	tokenNumber
+	tokenSymbol
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+var multiCharSymbols = []string{"->>", "<=>", "->", "<=", ">=", "!=", "<>", "<<", ">>", "||", "&&", ":="}
+
+// tokenize splits the query into tokens. Concatenating all tokens' text
+// results in the original query.
+func tokenize(query string) []token {
+	var tokens []token
+	for i := 0; i < len(query); {
+		ch := query[i]
+		start := i
+		kind := tokenSymbol
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			kind = tokenSpace
+			for i < len(query) && strings.IndexByte(" \t\n\r", query[i]) >= 0 {
+				i++
+			}
+		case strings.HasPrefix(query[i:], "--") || ch == '#':
+			kind = tokenSpace
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(query[i:], "/*"):
+			kind = tokenSpace
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				i = len(query)
+			} else {
+				i += end + 4
+			}
+		case ch == '\'' || ch == '"' || ch == '`':
+			kind = tokenString
+			if ch == '`' {
+				kind = tokenQuotedIdent
+			}
+			i = scanQuoted(query, i)
+		case isIdentStart(ch):
+			kind = tokenIdent
+			for i < len(query) && isIdentChar(query[i]) {
+				i++
+			}
+		case isDigit(ch) || (ch == '.' && i+1 < len(query) && isDigit(query[i+1])):
+			kind = tokenNumber
+			for i < len(query) && (isDigit(query[i]) || query[i] == '.') {
+				i++
+			}
+			if i < len(query) && (query[i] == 'e' || query[i] == 'E') {
+				i++
+				if i < len(query) && (query[i] == '+' || query[i] == '-') {
+					i++
+				}
+				for i < len(query) && isDigit(query[i]) {
+					i++
+				}
+			}
+		default:
+			i++
+			for _, symbol := range multiCharSymbols {
+				if strings.HasPrefix(query[start:], symbol) {
+					i = start + len(symbol)
+					break
+				}
+			}
+		}
+		tokens = append(tokens, token{kind: kind, text: query[start:i]})
+	}
+	return tokens
+}
+
+// scanQuoted returns the index after the quoted string or identifier starting at i.
+// Quotes are escaped by doubling them or, except in identifiers, by a backslash.
+func scanQuoted(query string, i int) int {
+	quote := query[i]
+	i++
+	for i < len(query) {
+		switch {
+		case query[i] == '\\' && quote != '`':
+			i += 2
+		case query[i] == quote && i+1 < len(query) && query[i+1] == quote:
+			i += 2
+		case query[i] == quote:
+			return i + 1
+		default:
+			i++
+		}
+	}
+	return len(query)
+}
+
+func isIdentStart(ch byte) bool {
+	return ch == '_' || ch == '$' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || ch >= 0x80
+}
+
+func isIdentChar(ch byte) bool {
+	return isIdentStart(ch) || isDigit(ch)
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+func joinTokens(tokens []token) string {
+	var sb strings.Builder
+	for _, t := range tokens {
+		sb.WriteString(t.text)
+	}
+	return sb.String()
+}
+
+// isKeyword reports whether the token is the given (case-insensitive) keyword.
+func (t token) isKeyword(keyword string) bool {
+	return t.kind == tokenIdent && strings.EqualFold(t.text, keyword)
+}
+
+// prevToken returns index of the previous non-space token before i, or -1.
+func prevToken(tokens []token, i int) int {
+	for i--; i >= 0 && tokens[i].kind == tokenSpace; i-- {
+	}
+	return i
+}
+
+// nextToken returns index of the next non-space token after i, or len(tokens).
+func nextToken(tokens []token, i int) int {
+	for i++; i < len(tokens) && tokens[i].kind == tokenSpace; i++ {
+	}
+	return i
+}
+
+// rewriteQuery rewrites FireQL specific syntax of the query into syntax the SQL parser accepts.
+func rewriteQuery(query string) string {
+	tokens := tokenize(query)
+	tokens = rewriteFieldPaths(tokens)
+	tokens = rewriteCasts(tokens)
+	tokens = rewriteFunctionNames(tokens)
+	tokens = rewriteDerefs(tokens)
+	tokens = rewriteSubscripts(tokens)
+	tokens = rewriteTypedLiterals(tokens)
+	tokens = rewriteContains(tokens)
+	tokens = rewriteILike(tokens)
+	tokens = rewriteNullsOrder(tokens)
+	tokens = rewriteAtTimeZone(tokens)
+	tokens = rewriteExtract(tokens)
+	tokens = rewriteDateParts(tokens)
+	return joinTokens(tokens)
+}
+
+// rewriteFieldPaths rewrites nested field references such as address.`zip-code` or
+// a.b.c.d into a single quoted identifier holding the field path in Firestore syntax,
+// as the parser allows at most three dotted names. Quoted names are single path
+// segments, so they can contain dots, also on their own:
+//
+//	address.`zip-code` -> `address.``zip-code```
+//	`a.b`              -> ```a.b```
+func rewriteFieldPaths(tokens []token) []token {
+	var result []token
+	for i := 0; i < len(tokens); i++ {
+		end := i
+		for end+2 < len(tokens) && tokens[end+1].text == "." && isName(tokens[end]) && isName(tokens[end+2]) {
+			end += 2
+		}
+		if (end == i && !isQuotedField(tokens, i)) || (end+1 < len(tokens) && tokens[end+1].text == "(") {
+			result = append(result, tokens[i:end+1]...)
+			i = end
+			continue
+		}
+		var fieldPath firestore.FieldPath
+		for j := i; j <= end; j += 2 {
+			segment := tokens[j].text
+			if tokens[j].kind == tokenQuotedIdent {
+				segment = strings.ReplaceAll(segment[1:len(segment)-1], "``", "`")
+			}
+			fieldPath = append(fieldPath, segment)
+		}
+		quoted := "`" + strings.ReplaceAll(fieldPathString(fieldPath), "`", "``") + "`"
+		result = append(result, token{kind: tokenQuotedIdent, text: quoted})
+		i = end
+	}
+	return result
+}
+
+// keywordFunctions are functions the parser treats as keywords with special syntax.
+var keywordFunctions = []string{"substr", "substring", "cast"}
+
+// rewriteFunctionNames quotes names of function calls the parser treats as keywords,
+// e.g. SUBSTR('abc', 2) into `SUBSTR`('abc', 2), to parse them as regular functions.
+func rewriteFunctionNames(tokens []token) []token {
+	result := make([]token, len(tokens))
+	for i, t := range tokens {
+		result[i] = t
+		if t.kind != tokenIdent {
+			continue
+		}
+		if next := nextToken(tokens, i); next < len(tokens) && tokens[next].text == "(" {
+			for _, name := range keywordFunctions {
+				if t.isKeyword(name) {
+					result[i] = token{kind: tokenQuotedIdent, text: "`" + t.text + "`"}
+				}
+			}
+		}
+	}
+	return result
+}
+
+// rewriteCasts rewrites "CAST(x AS INT)" and "SAFE_CAST(x AS INT)" into CAST(x, 'INT')
+// and SAFE_CAST(x, 'INT'), as the parser only supports casts to MySQL types.
+func rewriteCasts(tokens []token) []token {
+	result := append([]token{}, tokens...)
+	for i, t := range tokens {
+		open := nextToken(tokens, i)
+		if (!t.isKeyword("cast") && !t.isKeyword("safe_cast")) || open >= len(tokens) || tokens[open].text != "(" {
+			continue
+		}
+		closing := closingParen(tokens, open) - 1
+		typeIdx := prevToken(tokens, closing)
+		asIdx := prevToken(tokens, typeIdx)
+		if asIdx <= open || tokens[typeIdx].kind != tokenIdent || !tokens[asIdx].isKeyword("as") {
+			continue
+		}
+		result[asIdx] = token{kind: tokenSymbol, text: ","}
+		result[typeIdx] = token{kind: tokenString, text: quoteString(strings.ToUpper(tokens[typeIdx].text))}
+	}
+	return result
+}
+
+// rewriteDerefs rewrites dereferences of document references such as author->name and
+// DEREF(author).name into DEREF(author, 'name'), as the parser doesn't support them.
+func rewriteDerefs(tokens []token) []token {
+	var result []token
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		end := prevToken(result, len(result))
+		start := operandStart(result, end)
+		pathIdx := nextToken(tokens, i)
+		isDeref := t.text == "->" || (t.text == "." && end >= 0 && result[end].text == ")" && result[start].isKeyword("deref"))
+		if !isDeref || start < 0 || pathIdx >= len(tokens) || !isName(tokens[pathIdx]) {
+			result = append(result, t)
+			continue
+		}
+		path := tokens[pathIdx].text
+		if tokens[pathIdx].kind == tokenQuotedIdent {
+			path = strings.ReplaceAll(path[1:len(path)-1], "``", "`")
+		}
+		pathArg := []token{{kind: tokenString, text: quoteString(path)}}
+		operand := append([]token{}, result[start:end+1]...)
+		if t.text == "." && !multipleArguments(operand) {
+			// DEREF(author).name reads the field of the document DEREF(author) reads
+			open := nextToken(operand, 0)
+			result = append(result[:start], functionCall("DEREF", operand[open+1:len(operand)-1], pathArg)...)
+		} else {
+			result = append(result[:start], functionCall("DEREF", operand, pathArg)...)
+		}
+		i = pathIdx
+	}
+	return result
+}
+
+// multipleArguments reports whether the function call has more than one argument.
+func multipleArguments(call []token) bool {
+	depth := 0
+	for _, t := range call {
+		switch t.text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		case ",":
+			if depth == 1 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// rewriteSubscripts rewrites element access such as tags[0] or address['city'] into
+// GET(tags, 0) and GET(address, 'city'), as the parser doesn't support subscripts.
+func rewriteSubscripts(tokens []token) []token {
+	var result []token
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		if t.text != "[" {
+			result = append(result, t)
+			continue
+		}
+		end := prevToken(result, len(result))
+		start := operandStart(result, end)
+		closing := closingBracket(tokens, i)
+		if start < 0 || closing >= len(tokens) {
+			result = append(result, t)
+			continue
+		}
+		operand := append([]token{}, result[start:end+1]...)
+		// subscripts may contain subscripts, e.g. a[b[0]]
+		index := rewriteSubscripts(tokens[i+1 : closing])
+		result = append(result[:start], functionCall("GET", operand, index)...)
+		i = closing
+	}
+	return result
+}
+
+// closingBracket returns the index of the bracket closing the one at index open.
+func closingBracket(tokens []token, open int) int {
+	depth := 0
+	for i := open; i < len(tokens); i++ {
+		switch tokens[i].text {
+		case "[":
+			depth++
+		case "]":
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(tokens)
+}
+
+// isQuotedField reports whether the token at index i is a quoted field name, rather than
+// a quoted collection, alias or function name.
+func isQuotedField(tokens []token, i int) bool {
+	if tokens[i].kind != tokenQuotedIdent || len(tokens[i].text) < 2 {
+		return false
+	}
+	prev := prevToken(tokens, i)
+	if prev < 0 {
+		return true
+	}
+	switch t := tokens[prev]; {
+	case t.kind == tokenIdent:
+		// other names are followed by aliases without AS, e.g. name `full name`
+		for _, keyword := range fieldKeywords {
+			if t.isKeyword(keyword) {
+				return true
+			}
+		}
+		return false
+	case t.kind == tokenQuotedIdent, t.kind == tokenString, t.kind == tokenNumber, t.text == ")":
+		return false
+	}
+	return true
+}
+
+// fieldKeywords are keywords fields can follow, unlike FROM, JOIN and AS followed by
+// collection names and aliases.
+var fieldKeywords = []string{"select", "distinct", "where", "having", "on", "by", "and", "or", "xor", "not", "case", "when",
+	"then", "else", "between", "like", "regexp", "rlike", "div", "mod", "interval", "contains", "any"}
+
+func isName(t token) bool {
+	return t.kind == tokenIdent || (t.kind == tokenQuotedIdent && len(t.text) >= 2)
+}
+
+// rewriteTypedLiterals rewrites typed literals such as TIMESTAMP '2024-01-01T00:00:00Z'
+// and DATE '2024-01-01' into function calls.
+func rewriteTypedLiterals(tokens []token) []token {
+	var result []token
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		if t.isKeyword("timestamp") || t.isKeyword("date") {
+			next := nextToken(tokens, i)
+			if next < len(tokens) && tokens[next].kind == tokenString {
+				result = append(result, t,
+					token{kind: tokenSymbol, text: "("},
+					tokens[next],
+					token{kind: tokenSymbol, text: ")"})
+				i = next
+				continue
+			}
+		}
+		result = append(result, t)
+	}
+	return result
+}
+
+// fieldStart returns start index of the field reference, e.g. address.`zip-code`,
+// ending at index end, or -1 if the token at end isn't a field reference.
+func fieldStart(tokens []token, end int) int {
+	if end < 0 || (tokens[end].kind != tokenIdent && tokens[end].kind != tokenQuotedIdent) {
+		return -1
+	}
+	start := end
+	for start >= 2 && tokens[start-1].text == "." &&
+		(tokens[start-2].kind == tokenIdent || tokens[start-2].kind == tokenQuotedIdent) {
+		start -= 2
+	}
+	return start
+}
+
+// operandEnd returns the index after the operand starting at index start. An operand is
+// a single token, a parenthesized list, a function call, a signed number or a bind variable.
+func operandEnd(tokens []token, start int) int {
+	if start >= len(tokens) {
+		return start
+	}
+	t := tokens[start]
+	switch {
+	case t.text == "(":
+		return closingParen(tokens, start)
+	case t.kind == tokenIdent:
+		if next := nextToken(tokens, start); next < len(tokens) && tokens[next].text == "(" {
+			return closingParen(tokens, next)
+		}
+	case t.text == "-" || t.text == "+" || t.text == ":":
+		if next := nextToken(tokens, start); next < len(tokens) {
+			return next + 1
+		}
+	}
+	return start + 1
+}
+
+// closingParen returns the index after the parenthesis closing the one at index open.
+func closingParen(tokens []token, open int) int {
+	depth := 0
+	for i := open; i < len(tokens); i++ {
+		switch tokens[i].text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(tokens)
+}
+
+// functionCall returns tokens calling the function with the arguments.
+func functionCall(name string, args ...[]token) []token {
+	call := []token{{kind: tokenIdent, text: name}, {kind: tokenSymbol, text: "("}}
+	for idx, arg := range args {
+		if idx > 0 {
+			call = append(call, token{kind: tokenSymbol, text: ","}, token{kind: tokenSpace, text: " "})
+		}
+		call = append(call, arg...)
+	}
+	return append(call, token{kind: tokenSymbol, text: ")"})
+}
+
+// rewriteContains rewrites array membership conditions "tags CONTAINS 'x'" and
+// "tags CONTAINS ANY ('x', 'y')" into ARRAY_CONTAINS(tags, 'x') and
+// ARRAY_CONTAINS_ANY(tags, ('x', 'y')) function calls.
+func rewriteContains(tokens []token) []token {
+	var result []token
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		if !t.isKeyword("contains") {
+			result = append(result, t)
+			continue
+		}
+		fieldEnd := prevToken(result, len(result))
+		start := fieldStart(result, fieldEnd)
+		function := "array_contains"
+		valStart := nextToken(tokens, i)
+		if valStart < len(tokens) && tokens[valStart].isKeyword("any") {
+			function = "array_contains_any"
+			valStart = nextToken(tokens, valStart)
+		}
+		// otherwise contains is a field, e.g. in "WHERE contains = 1"
+		if start < 0 || isSQLKeyword(result[fieldEnd]) || !startsOperand(tokens, valStart) {
+			result = append(result, t)
+			continue
+		}
+		valEnd := operandEnd(tokens, valStart)
+		field := append([]token{}, result[start:fieldEnd+1]...)
+		result = append(result[:start], functionCall(function, field, tokens[valStart:valEnd])...)
+		i = valEnd - 1
+	}
+	return result
+}
+
+// sqlKeywords are keywords that aren't field names.
+var sqlKeywords = []string{"select", "distinct", "from", "where", "group", "having", "order", "by", "limit", "offset",
+	"join", "inner", "left", "right", "cross", "on", "as", "and", "or", "xor", "not", "is", "in", "like", "regexp",
+	"rlike", "between", "case", "when", "then", "else", "end", "div", "mod", "interval", "asc", "desc", "union"}
+
+func isSQLKeyword(t token) bool {
+	for _, keyword := range sqlKeywords {
+		if t.isKeyword(keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// startsOperand reports whether the token at index i starts an operand: a value, a
+// field, a function call, a parenthesized list, a signed number or a bind variable.
+func startsOperand(tokens []token, i int) bool {
+	if i >= len(tokens) {
+		return false
+	}
+	switch t := tokens[i]; t.kind {
+	case tokenString, tokenNumber, tokenQuotedIdent:
+		return true
+	case tokenIdent:
+		return !isSQLKeyword(t)
+	default:
+		return t.text == "(" || t.text == "?" || t.text == ":" || t.text == "-" || t.text == "+"
+	}
+}
+
+// rewriteILike rewrites case-insensitive "name ILIKE 'ab%'" into the equivalent
+// "name REGEXP '(?si)^ab.*$'" as the parser doesn't support ILIKE.
+func rewriteILike(tokens []token) []token {
+	var result []token
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		if t.isKeyword("ilike") {
+			next := nextToken(tokens, i)
+			if next < len(tokens) && tokens[next].kind == tokenString {
+				regex := likeToRegexp(unquoteString(tokens[next].text), defaultLikeEscape, true)
+				result = append(result, token{kind: tokenIdent, text: "REGEXP"}, token{kind: tokenSpace, text: " "},
+					token{kind: tokenString, text: quoteString(regex)})
+				i = next
+				continue
+			}
+		}
+		result = append(result, t)
+	}
+	return result
+}
+
+// rewriteNullsOrder rewrites "ORDER BY age DESC NULLS FIRST" into "ORDER BY NULLS_FIRST(age) DESC",
+// as the parser doesn't support NULLS FIRST and NULLS LAST.
+func rewriteNullsOrder(tokens []token) []token {
+	var result []token
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		next := nextToken(tokens, i)
+		if !t.isKeyword("nulls") || next == len(tokens) || !(tokens[next].isKeyword("first") || tokens[next].isKeyword("last")) {
+			result = append(result, t)
+			continue
+		}
+		end := prevToken(result, len(result))
+		var direction []token
+		if end >= 0 && (result[end].isKeyword("asc") || result[end].isKeyword("desc")) {
+			direction = []token{{kind: tokenSpace, text: " "}, result[end]}
+			end = prevToken(result, end)
+		}
+		start := orderItemStart(result, end)
+		if start < 0 || start > end {
+			result = append(result, t)
+			continue
+		}
+		item := append([]token{}, result[start:end+1]...)
+		result = append(result[:start], functionCall("nulls_"+strings.ToLower(tokens[next].text), item)...)
+		result = append(result, direction...)
+		i = next
+	}
+	return result
+}
+
+// rewriteAtTimeZone rewrites "created_at AT TIME ZONE 'America/New_York'" into
+// CONVERT_TZ(created_at, 'America/New_York').
+func rewriteAtTimeZone(tokens []token) []token {
+	var result []token
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		timeIdx := nextToken(tokens, i)
+		zoneIdx := nextToken(tokens, timeIdx)
+		if !t.isKeyword("at") || zoneIdx >= len(tokens) || !tokens[timeIdx].isKeyword("time") || !tokens[zoneIdx].isKeyword("zone") {
+			result = append(result, t)
+			continue
+		}
+		end := prevToken(result, len(result))
+		start := operandStart(result, end)
+		zoneStart := nextToken(tokens, zoneIdx)
+		if start < 0 || zoneStart >= len(tokens) {
+			result = append(result, t)
+			continue
+		}
+		zoneEnd := operandEnd(tokens, zoneStart)
+		operand := append([]token{}, result[start:end+1]...)
+		result = append(result[:start], functionCall("CONVERT_TZ", operand, tokens[zoneStart:zoneEnd])...)
+		i = zoneEnd - 1
+	}
+	return result
+}
+
+// operandStart returns start index of the operand ending at index end, a field reference,
+// a parenthesized expression, a function call or a literal, or -1 if there is none.
+func operandStart(tokens []token, end int) int {
+	if end < 0 {
+		return -1
+	}
+	switch t := tokens[end]; {
+	case t.text == ")":
+		depth := 0
+		for i := end; i >= 0; i-- {
+			switch tokens[i].text {
+			case ")":
+				depth++
+			case "(":
+				depth--
+				if depth == 0 {
+					if name := prevToken(tokens, i); name >= 0 && tokens[name].kind == tokenIdent || name >= 0 && tokens[name].kind == tokenQuotedIdent {
+						return name
+					}
+					return i
+				}
+			}
+		}
+		return -1
+	case t.kind == tokenString || t.kind == tokenNumber:
+		return end
+	}
+	return fieldStart(tokens, end)
+}
+
+// rewriteExtract rewrites "EXTRACT(YEAR FROM created_at)" into EXTRACT('YEAR', created_at),
+// as the parser doesn't support EXTRACT.
+func rewriteExtract(tokens []token) []token {
+	var result []token
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		open := nextToken(tokens, i)
+		part := nextToken(tokens, open)
+		from := nextToken(tokens, part)
+		if !t.isKeyword("extract") || from >= len(tokens) || tokens[open].text != "(" ||
+			tokens[part].kind != tokenIdent || !tokens[from].isKeyword("from") {
+			result = append(result, t)
+			continue
+		}
+		result = append(result, t,
+			token{kind: tokenSymbol, text: "("},
+			token{kind: tokenString, text: quoteString(strings.ToUpper(tokens[part].text))},
+			token{kind: tokenSymbol, text: ","})
+		i = from
+	}
+	return result
+}
+
+// dateParts are the parts of timestamps date functions accept.
+var dateParts = []string{"microsecond", "millisecond", "second", "minute", "hour", "day", "dayofweek", "dayofyear",
+	"week", "isoweek", "month", "quarter", "year", "isoyear"}
+
+// datePartFunctions are functions taking a date part as their last argument.
+var datePartFunctions = []string{"date_trunc", "timestamp_trunc", "timestamp_diff"}
+
+// rewriteDateParts rewrites date parts passed to date functions as keywords into strings,
+// e.g. DATE_TRUNC(created_at, DAY) into DATE_TRUNC(created_at, 'DAY').
+func rewriteDateParts(tokens []token) []token {
+	result := append([]token{}, tokens...)
+	for i, t := range tokens {
+		open := nextToken(tokens, i)
+		if t.kind != tokenIdent || open >= len(tokens) || tokens[open].text != "(" || !isKeywordOf(t, datePartFunctions) {
+			continue
+		}
+		last := prevToken(tokens, closingParen(tokens, open)-1)
+		if last > open && tokens[last].kind == tokenIdent && isKeywordOf(tokens[last], dateParts) &&
+			tokens[prevToken(tokens, last)].text == "," {
+			result[last] = token{kind: tokenString, text: quoteString(strings.ToUpper(tokens[last].text))}
+		}
+	}
+	return result
+}
+
+func isKeywordOf(t token, keywords []string) bool {
+	for _, keyword := range keywords {
+		if t.isKeyword(keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// orderItemStart returns start index of the ORDER BY item ending at index end,
+// or -1 if it isn't in an ORDER BY clause.
+func orderItemStart(tokens []token, end int) int {
+	depth := 0
+	for i := end; i >= 0; i-- {
+		switch {
+		case tokens[i].text == ")":
+			depth++
+		case tokens[i].text == "(":
+			depth--
+			if depth < 0 {
+				return -1
+			}
+		case depth == 0 && (tokens[i].text == "," || tokens[i].isKeyword("by")):
+			return nextToken(tokens, i)
+		}
+	}
+	return -1
+}
+
+// unquoteString returns value of the quoted string literal.
+func unquoteString(literal string) string {
+	quote := literal[0]
+	literal = literal[1 : len(literal)-1]
+	var sb strings.Builder
+	for i := 0; i < len(literal); i++ {
+		ch := literal[i]
+		switch {
+		case ch == '\\' && i+1 < len(literal):
+			i++
+			switch literal[i] {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case 'r':
+				sb.WriteByte('\r')
+			case '0':
+				sb.WriteByte(0)
+			case '%', '_':
+				// LIKE wildcards stay escaped
+				sb.WriteByte('\\')
+				sb.WriteByte(literal[i])
+			default:
+				sb.WriteByte(literal[i])
+			}
+		case ch == quote && i+1 < len(literal) && literal[i+1] == quote:
+			i++
+			sb.WriteByte(quote)
+		default:
+			sb.WriteByte(ch)
+		}
+	}
+	return sb.String()
+}
+
+// quoteString returns the value as a single quoted string literal.
+func quoteString(val string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `''`).Replace(val) + "'"
+}
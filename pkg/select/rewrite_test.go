@@ -0,0 +1,114 @@
+package _select
+
+import (
+	"testing"
+)
+
+func TestTokenizeRoundTrip(t *testing.T) {
+	queries := []string{
+		"select * from users",
+		"select `address.city`, 'it''s', \"a\\\"b\" from `[contacts]` -- comment\nwhere id >= 1.5e3 /* block */ and a->>'$.b' <> 2",
+	}
+	for _, query := range queries {
+		if actual := joinTokens(tokenize(query)); actual != query {
+			t.Errorf("joinTokens(tokenize(%q)) = %q", query, actual)
+		}
+	}
+}
+
+func TestRewriteQuery(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{
+			query:    "select * from users where created_at > TIMESTAMP '2024-01-01T00:00:00Z'",
+			expected: "select * from users where created_at > TIMESTAMP('2024-01-01T00:00:00Z')",
+		},
+		{
+			query:    "select SUBSTR(name, 2), substr from users",
+			expected: "select `SUBSTR`(name, 2), substr from users",
+		},
+		{
+			query:    "select * from users where born = date  '2000-01-01' and name = 'date'",
+			expected: "select * from users where born = date('2000-01-01') and name = 'date'",
+		},
+		{
+			query:    "select * from posts where tags CONTAINS 'go' and author.`first-name` contains any ('a', 'b')",
+			expected: "select * from posts where array_contains(tags, 'go') and array_contains_any(`author.``first-name```, ('a', 'b'))",
+		},
+		{
+			query:    "select * from posts where tags contains REF('users/a') and name = 'contains'",
+			expected: "select * from posts where array_contains(tags, REF('users/a')) and name = 'contains'",
+		},
+		{
+			query:    "select * from t where contains = 1",
+			expected: "select * from t where contains = 1",
+		},
+		{
+			query:    "select contains from t",
+			expected: "select contains from t",
+		},
+		{
+			query:    "select contains from t where contains contains 'x' and contains is not null",
+			expected: "select contains from t where array_contains(contains, 'x') and contains is not null",
+		},
+		{
+			query:    "select * from posts where scores contains -1",
+			expected: "select * from posts where array_contains(scores, -1)",
+		},
+		{
+			query:    "select * from users where name ILIKE 'ter%' and email not ilike '%.EDU'",
+			expected: `select * from users where name REGEXP '(?si)^ter.*$' and email not REGEXP '(?si)^.*\\.EDU$'`,
+		},
+		{
+			query:    "select a.b.c.d, address.`zip-code` z from users order by `a b`.c, t.* ",
+			expected: "select `a.b.c.d`, `address.``zip-code``` z from users order by ```a b``.c`, t.* ",
+		},
+		{
+			query:    "select `address.city`, 1.5, count(*) from users where `x.y`.z = 'a.b'",
+			expected: "select ```address.city```, 1.5, count(*) from users where ```x.y``.z` = 'a.b'",
+		},
+		{
+			query:    "select `a.b` as `c.d`, name `e.f`, `x-y` from `users/a.b/orders` o where not `a.b` and o.`a.b` = 1 order by `c.d`",
+			expected: "select ```a.b``` as `c.d`, name `e.f`, ```x-y``` from `users/a.b/orders` o where not ```a.b``` and `o.``a.b``` = 1 order by ```c.d```",
+		},
+		{
+			query:    "select * from users order by age DESC nulls first, length(tags) nulls last limit 5",
+			expected: "select * from users order by nulls_first(age) DESC, nulls_last(length(tags)) limit 5",
+		},
+		{
+			query:    "select extract(YEAR from created_at at time zone 'Asia/Kolkata'), date_trunc(created_at, day) from users",
+			expected: "select extract('YEAR', CONVERT_TZ(created_at, 'Asia/Kolkata')), date_trunc(created_at, 'DAY') from users",
+		},
+		{
+			query:    "select timestamp_diff(now(), created_at, hour) AT TIME ZONE 'UTC' from users",
+			expected: "select CONVERT_TZ(timestamp_diff(now(), created_at, 'HOUR'), 'UTC') from users",
+		},
+		{
+			query:    "select tags[0], address.lines[length(tags) - 1]['zip'], split(email, '@')[1] from users where tags[ids[0]] = 'go'",
+			expected: "select GET(tags, 0), GET(GET(`address.lines`, length(tags) - 1), 'zip'), GET(split(email, '@'), 1) from users where GET(tags, GET(ids, 0)) = 'go'",
+		},
+		{
+			query:    "select cast(age as string), SAFE_CAST(substr(zip, 1) AS int) from users where cast(`x.y` as Bool)",
+			expected: "select `cast`(age , 'STRING'), SAFE_CAST(`substr`(zip, 1) , 'INT') from users where `cast`(```x.y``` , 'BOOL')",
+		},
+		{
+			query:    "select timestamp, `date` from users",
+			expected: "select timestamp, `date` from users",
+		},
+		{
+			query:    "select * from users where tags contains ? and address.city = :city and tags[?] = 'go'",
+			expected: "select * from users where array_contains(tags, ?) and `address.city` = :city and GET(tags, ?) = 'go'",
+		},
+		{
+			query:    "select author->name, post->author -> address.city, author->tags[0], deref(author).`first-name`, DEREF(author) from posts p where p.author->age > 18",
+			expected: "select DEREF(author, 'name'), DEREF(DEREF(post, 'author'), 'address.city'), GET(DEREF(author, 'tags'), 0), DEREF(author, '`first-name`'), DEREF(author) from posts p where DEREF(`p.author`, 'age') > 18",
+		},
+	}
+	for _, tt := range tests {
+		if actual := rewriteQuery(tt.query); actual != tt.expected {
+			t.Errorf("rewriteQuery(%q): expected %q, actual %q", tt.query, tt.expected, actual)
+		}
+	}
+}
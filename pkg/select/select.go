@@ -2,25 +2,49 @@ package _select
 
 import (
 	"cloud.google.com/go/firestore"
-	vkit "cloud.google.com/go/firestore/apiv1"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/Knetic/govaluate"
 	"github.com/pgollangi/fireql/pkg/support"
 	"github.com/pgollangi/fireql/pkg/util"
 	"github.com/xwb1989/sqlparser"
-	"golang.org/x/oauth2/google"
 	"google.golang.org/api/iterator"
-	"google.golang.org/api/option"
-	"strconv"
-	"strings"
+	"time"
 )
 
 type SelectStatement struct {
-	context  *util.Context
-	rawQuery string
+	context    *util.Context
+	rawQuery   string
+	fireClient *firestore.Client
+	// sharedClient is set when fireClient is shared with other statements, which close it
+	sharedClient bool
+	collection   *util.Collection
+	// filters added to the query
+	filters []queryFilter
+	// fields selected by the query, all fields if empty
+	projection []firestore.FieldPath
+	// conditions evaluated on documents read from Firestore
+	clientFilters []*selectColumn
+	// order results are sorted by on the client side
+	clientOrder []*orderColumn
+	// limit applied on the client side when there are client filters or order
+	clientLimit int
+	// orders of the Firestore query
+	queryOrders []queryOrder
+	// field of the first inequality filter, which Firestore orders by implicitly
+	inequalityField firestore.FieldPath
+	// orders identifying position of a document for page tokens
+	cursorOrders []queryOrder
+	// number of records to return, 0 if not limited
+	rowLimit  int
+	pageSize  int
+	pageToken string
+	// counters of the query run by EXPLAIN ANALYZE, nil otherwise
+	stats *queryStats
+	// join with another collection, nil if the query reads a single collection
+	join *joinPlan
+	// references dereferenced by columns, client filters and order, nested ones first
+	derefs []evaluator
 }
 
 type SelectResult struct {
@@ -30,158 +54,397 @@ type SelectResult struct {
 
 func New(context *util.Context, rawQuery string) *SelectStatement {
 	return &SelectStatement{
-		context,
-		rawQuery,
+		context:  context,
+		rawQuery: rawQuery,
 	}
 }
 
+// NewWithClient returns the statement using the Firestore client instead of creating its own,
+// e.g. to compile many queries. The client isn't closed by the statement.
+func NewWithClient(context *util.Context, rawQuery string, fireClient *firestore.Client) *SelectStatement {
+	return &SelectStatement{
+		context:      context,
+		rawQuery:     rawQuery,
+		fireClient:   fireClient,
+		sharedClient: true,
+	}
+}
+
+// closeClient closes the Firestore client created by the statement.
+func (sel *SelectStatement) closeClient() error {
+	if sel.fireClient == nil || sel.sharedClient {
+		return nil
+	}
+	return sel.fireClient.Close()
+}
+
+// documentIterator iterates over documents read from Firestore.
+type documentIterator interface {
+	Next() (*firestore.DocumentSnapshot, error)
+}
+
+// rowIterator iterates over data of documents, or of joined documents.
+type rowIterator interface {
+	Next() (*firestore.DocumentSnapshot, map[string]interface{}, error)
+}
+
+// documentRows iterates over data of documents read from Firestore.
+type documentRows struct {
+	docs documentIterator
+}
+
+func (it *documentRows) Next() (*firestore.DocumentSnapshot, map[string]interface{}, error) {
+	document, err := it.docs.Next()
+	if err != nil {
+		return nil, nil, err
+	}
+	return document, document.Data(), nil
+}
+
+// queryPlan is the query compiled from SQL.
+type queryPlan struct {
+	sQuery *sqlparser.Select
+	query  firestore.Query
+	// documents read directly instead of running the query, when not nil
+	docRefs []*firestore.DocumentRef
+	columns []*selectColumn
+	// names of the query parameters
+	params []string
+}
+
 func (sel *SelectStatement) Execute() (*util.QueryResult, error) {
-	stmt, err := sqlparser.Parse(sel.rawQuery)
+	plan, err := sel.compile()
+	defer sel.closeClient()
 	if err != nil {
 		return nil, err
 	}
+	return sel.run(plan, nil)
+}
 
-	sQuery := stmt.(*sqlparser.Select)
+// run reads documents of the compiled query, with args as values of the query parameters.
+// It doesn't change the statement, so that the plan can be run concurrently.
+func (sel *SelectStatement) run(plan *queryPlan, args []interface{}) (*util.QueryResult, error) {
+	binds, err := plan.bind(args)
+	if err != nil {
+		return nil, err
+	}
+	var docs documentIterator
+	if plan.docRefs != nil {
+		if docs, err = sel.getAll(plan.docRefs); err != nil {
+			return nil, err
+		}
+	} else {
+		querySel := sel
+		if sel.join != nil {
+			querySel = sel.join.left
+		}
+		fQuery, err := querySel.bindQuery(plan.query, binds)
+		if err != nil {
+			return nil, err
+		}
+		if sel.stats != nil {
+			fQuery = fQuery.WithRunOptions(explainOptions)
+		}
+		queryDocs := fQuery.Documents(context.Background())
+		defer queryDocs.Stop()
+		docs = queryDocs
+	}
+	if sel.stats != nil {
+		docs = &statsIterator{docs: docs, stats: sel.stats, lookup: plan.docRefs != nil}
+	}
+	env := &evalEnv{binds: binds, refs: newRefCache(sel)}
+	var rows rowIterator = &documentRows{docs: docs}
+	if sel.join != nil {
+		rows = &joinIterator{sel: sel, join: sel.join, docs: docs, env: env}
+	}
+	if len(sel.derefs) > 0 {
+		batchSize := derefBatchSize
+		if sel.rowLimit > 0 && sel.rowLimit < batchSize {
+			batchSize = sel.rowLimit
+		}
+		rows = &derefIterator{rows: rows, derefs: sel.derefs, env: env, batchSize: batchSize}
+	}
+	return sel.readResults(rows, plan.columns, env)
+}
 
-	from := sQuery.From
-	if len(from) != 1 {
-		return nil, errors.New("there must be a FROM collection")
+// compile translates the SQL query into a Firestore query, without running it.
+// The Firestore client it creates, unless given one, must be closed by the caller.
+func (sel *SelectStatement) compile() (*queryPlan, error) {
+	query, pageToken := extractPageToken(sel.rawQuery)
+	if sel.pageToken == "" {
+		sel.pageToken = pageToken
+	}
+	parsed := rewriteQuery(query)
+	stmt, err := sqlparser.Parse(parsed)
+	if err != nil {
+		return nil, util.NewSyntaxError(sel.rawQuery, parsed, err)
 	}
-	qCollectionName := sqlparser.String(sQuery.From[0])
 
-	fireClient, err := sel.newFireClient()
+	sQuery, err := selectQuery(stmt)
 	if err != nil {
 		return nil, err
 	}
-	defer fireClient.Close()
 
-	qCollectionName = strings.Trim(qCollectionName, "`")
+	var qCollectionName string
+	if join, ok := joinExpr(sQuery); ok {
+		if sel.join, err = newJoinPlan(join); err != nil {
+			return nil, err
+		}
+		qCollectionName = sel.join.leftName
+	} else if qCollectionName, err = sel.collectionName(sQuery); err != nil {
+		return nil, err
+	}
 
-	var fQuery firestore.Query
-	if strings.HasPrefix(qCollectionName, "[") && strings.HasSuffix(qCollectionName, "]") {
-		groupName := strings.TrimPrefix(qCollectionName, "[")
-		groupName = strings.TrimSuffix(groupName, "]")
-		fQuery = fireClient.CollectionGroup(groupName).Query
-	} else {
-		fQuery = fireClient.Collection(qCollectionName).Query
+	if sel.fireClient == nil {
+		if sel.fireClient, err = util.NewFireClient(sel.context); err != nil {
+			return nil, err
+		}
 	}
 
-	fQuery, selectedFields, err := sel.selectFields(fQuery, sQuery)
+	sel.collection, err = util.ResolveCollection(sel.fireClient, qCollectionName)
 	if err != nil {
 		return nil, err
 	}
-	fQuery, err = sel.addWhere(fQuery, sQuery)
+
+	if sel.join == nil {
+		// e.g. users.email in SELECT users.email FROM users is the email field
+		unqualify(sQuery, tableQualifier(sQuery, sel.collection))
+	}
+
+	plan := &queryPlan{sQuery: sQuery, params: bindVarNames(sQuery)}
+	if sel.join != nil {
+		if err = sel.compileJoin(plan, sQuery); err != nil {
+			return nil, err
+		}
+		return plan, nil
+	}
+	if sel.pageToken == "" && sel.pageSize == 0 {
+		plan.docRefs, err = sel.lookupDocumentRefs(sQuery)
+		if err != nil {
+			return nil, err
+		}
+	}
+	plan.columns, err = sel.collectSelectColumns(sQuery.SelectExprs)
+	if err != nil {
+		return nil, err
+	}
+	if plan.docRefs != nil {
+		sel.rowLimit = sel.context.DefaultLimit
+		if sQuery.Limit != nil {
+			sel.rowLimit, err = sel.limitRows(sQuery.Limit)
+		}
+		return plan, err
+	}
+
+	fQuery, err := sel.addWhere(sel.collection.Query, sQuery)
 	if err != nil {
 		return nil, err
 	}
-	fQuery, err = sel.addLimit(fQuery, sQuery)
+	fQuery, err = sel.addOrderBy(fQuery, sQuery, plan.columns)
 	if err != nil {
 		return nil, err
 	}
-	fQuery, err = sel.addOrderBy(fQuery, sQuery)
+	fQuery, err = sel.addCursor(fQuery, sQuery.Limit != nil || sel.context.DefaultLimit > 0 || sel.pageSize > 0)
 	if err != nil {
 		return nil, err
 	}
-	docs := fQuery.Documents(context.Background())
-	return sel.readResults(docs, selectedFields)
+	fQuery = sel.selectFields(fQuery, plan.columns)
+	plan.query, err = sel.addLimit(fQuery, sQuery)
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
 }
 
-func (sel *SelectStatement) readResults(docs *firestore.DocumentIterator, selectedColumns []*selectColumn) (*util.QueryResult, error) {
-	document, err := docs.Next()
-	if errors.Is(err, iterator.Done) {
-		var columns []string
-		for _, column := range selectedColumns {
-			columns = append(columns, column.alias)
+// selectQuery returns the SELECT query of the statement, rejecting
+// statements and clauses that can't be translated into a Firestore query.
+func selectQuery(stmt sqlparser.Statement) (*sqlparser.Select, error) {
+	sQuery, ok := stmt.(*sqlparser.Select)
+	if !ok {
+		if _, isUnion := stmt.(*sqlparser.Union); isUnion {
+			return nil, util.NewUnsupportedError(util.CodeUnsupportedStatement, sqlparser.String(stmt), "UNION is not supported")
 		}
-		return &util.QueryResult{Columns: columns, Records: [][]interface{}{}}, nil
-	} else if err != nil {
-		return nil, err
+		return nil, util.NewUnsupportedError(util.CodeUnsupportedStatement, sqlparser.String(stmt), "unsupported statement: %s", sqlparser.String(stmt))
+	}
+	if sQuery.Distinct != "" {
+		return nil, util.NewUnsupportedError(util.CodeUnsupportedClause, "DISTINCT", "SELECT DISTINCT is not supported")
+	}
+	if len(sQuery.GroupBy) > 0 {
+		return nil, util.NewUnsupportedError(util.CodeUnsupportedClause, sqlparser.String(sQuery.GroupBy), "GROUP BY is not supported")
 	}
+	if sQuery.Having != nil {
+		return nil, util.NewUnsupportedError(util.CodeUnsupportedClause, sqlparser.String(sQuery.Having), "HAVING is not supported")
+	}
+	return sQuery, nil
+}
 
-	// Insert COLUMNS for START (*) selection
-	starIdx := -1
-	for idx, column := range selectedColumns {
-		if column.colType == Star {
-			starIdx = idx
-			break
-		}
+// collectionName returns the collection name, path or group in FROM clause.
+func (sel *SelectStatement) collectionName(sQuery *sqlparser.Select) (string, error) {
+	from := sQuery.From
+	if len(from) != 1 {
+		return "", util.NewUnsupportedError(util.CodeUnsupportedClause, sqlparser.String(from), "there must be a FROM collection")
 	}
-	if starIdx != -1 {
-		// Remove star Select as we insert real columns
-		selectedColumns = append(selectedColumns[:starIdx], selectedColumns[starIdx+1:]...)
-		data := document.Data()
-		for key := range data {
-			newCol := &selectColumn{
-				field:   key,
-				alias:   key,
-				colType: Field,
-			}
-			if len(selectedColumns) == starIdx {
-				selectedColumns = append(selectedColumns, newCol)
-			} else {
-				selectedColumns = append(selectedColumns[:starIdx+1], selectedColumns[starIdx:]...)
-				selectedColumns[starIdx] = newCol
-			}
-			starIdx++
+	if tableExpr, ok := from[0].(*sqlparser.AliasedTableExpr); ok {
+		if tableName, ok := tableExpr.Expr.(sqlparser.TableName); ok {
+			return tableName.Name.String(), nil
 		}
 	}
+	return "", util.NewUnsupportedError(util.CodeUnsupportedClause, sqlparser.String(from[0]), "unsupported FROM clause: %s", sqlparser.String(from[0]))
+}
 
-	var columns []string
-	var rows [][]interface{}
-
-	for _, column := range selectedColumns {
-		columns = append(columns, column.alias)
+// tableQualifier returns the name columns can be qualified by, the alias of the FROM
+// collection or else its ID.
+func tableQualifier(sQuery *sqlparser.Select, collection *util.Collection) string {
+	if tableExpr, ok := sQuery.From[0].(*sqlparser.AliasedTableExpr); ok && !tableExpr.As.IsEmpty() {
+		return tableExpr.As.String()
 	}
+	return collection.ID
+}
+
+func (sel *SelectStatement) readResults(docs rowIterator, selectedColumns []*selectColumn, env *evalEnv) (*util.QueryResult, error) {
+	var columns []string
+	rows := [][]interface{}{}
+	var orderValues [][]interface{}
+	var lastDocument *firestore.DocumentSnapshot
 
 	for {
-		row := make([]interface{}, len(columns))
-		rows = append(rows, row)
+		document, data, err := docs.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		} else if err != nil {
+			var filterErr *filterError
+			if errors.As(err, &filterErr) {
+				return nil, filterErr.err
+			}
+			return nil, util.NewFirestoreError(err)
+		}
+
+		matched, err := sel.matchClientFilters(document, &data, env)
+		if err != nil {
+			return nil, err
+		}
+		if !matched {
+			continue
+		}
 
-		data := document.Data()
+		if columns == nil {
+			selectedColumns = expandStarColumns(selectedColumns, data)
+			for _, column := range selectedColumns {
+				columns = append(columns, column.alias)
+			}
+		}
 
+		row := make([]interface{}, len(columns))
 		for idx, column := range selectedColumns {
-			val, err := readColumnValue(document, &data, column)
+			val, err := readColumnValue(document, &data, column, env)
 			if err != nil {
 				return nil, err
 			}
 			row[idx] = val
 		}
-		document, err = docs.Next()
-		if errors.Is(err, iterator.Done) {
+		rows = append(rows, row)
+		lastDocument = document
+
+		if len(sel.clientOrder) > 0 {
+			// All documents must be read before sorting
+			orderValues = append(orderValues, sel.readOrderValues(document, &data, env))
+		} else if sel.clientLimit > 0 && len(rows) == sel.clientLimit {
 			break
 		}
 	}
 
-	return &util.QueryResult{Columns: columns, Records: rows}, nil
+	if len(sel.clientOrder) > 0 {
+		rows = sel.sortRows(rows, orderValues)
+		if sel.clientLimit > 0 && len(rows) > sel.clientLimit {
+			rows = rows[:sel.clientLimit]
+		}
+	}
+
+	if columns == nil {
+		for _, column := range selectedColumns {
+			columns = append(columns, column.alias)
+		}
+	}
+	result := &util.QueryResult{Columns: columns, Records: rows}
+	if sel.cursorOrders != nil && sel.rowLimit > 0 && len(rows) == sel.rowLimit {
+		// There may be more records
+		nextPageToken, err := sel.nextPageToken(lastDocument)
+		if err != nil {
+			return nil, err
+		}
+		result.NextPageToken = nextPageToken
+	}
+	return result, nil
 }
 
-func readColumnValue(document *firestore.DocumentSnapshot, data *map[string]interface{}, column *selectColumn) (interface{}, error) {
+// expandStarColumns replaces star (*) selections with columns of all fields in data,
+// or of the joined document the star is qualified by, e.g. u.*.
+func expandStarColumns(selectedColumns []*selectColumn, data map[string]interface{}) []*selectColumn {
+	var columns []*selectColumn
+	for _, column := range selectedColumns {
+		if column.colType != Star {
+			columns = append(columns, column)
+			continue
+		}
+		if column.path == nil {
+			for key := range data {
+				columns = append(columns, newFieldColumn(firestore.FieldPath{key}, key))
+			}
+			continue
+		}
+		fields, _ := data[column.path[0]].(map[string]interface{})
+		for key := range fields {
+			if key != firestore.DocumentID {
+				path := firestore.FieldPath{column.path[0], key}
+				columns = append(columns, newFieldColumn(path, fieldPathString(path)))
+			}
+		}
+	}
+	return columns
+}
+
+// matchClientFilters evaluates client side filters on the document. The document doesn't
+// match when a filter is NULL or not a boolean, and errors evaluating filters are returned.
+func (sel *SelectStatement) matchClientFilters(document *firestore.DocumentSnapshot, data *map[string]interface{}, env *evalEnv) (bool, error) {
+	for _, filter := range sel.clientFilters {
+		val, err := readColumnValue(document, data, filter, env)
+		if err != nil {
+			return false, err
+		}
+		if matched, ok := val.(bool); !ok || !matched {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// filterError is returned by row iterators failing to evaluate client side filters,
+// which aren't Firestore errors.
+type filterError struct {
+	err error
+}
+
+func (e *filterError) Error() string {
+	return e.err.Error()
+}
+
+func (e *filterError) Unwrap() error {
+	return e.err
+}
+
+func readColumnValue(document *firestore.DocumentSnapshot, data *map[string]interface{}, column *selectColumn, env *evalEnv) (interface{}, error) {
 	var val interface{}
 	switch column.colType {
 	case Field:
-		if column.field == firestore.DocumentID {
-			val = document.Ref.ID
-		} else {
-			fieldPaths := strings.Split(column.field, ".")
-			var colData interface{}
-			colData = *data
-			for _, fPath := range fieldPaths {
-				fieldVal, ok := colData.(map[string]interface{})[fPath]
-				if !ok {
-					return nil, fmt.Errorf(`unknown field "%s" in doc "%s"`, column.field, document.Ref.ID)
-				}
-				colData = fieldVal
-				if colData == nil {
-					break
-				}
-			}
-			val = colData
+		fieldVal, ok := lookupField(document, *data, column.path)
+		if !ok {
+			return nil, fmt.Errorf(`unknown field "%s" in doc "%s"`, column.field, document.Ref.ID)
 		}
-		break
+		val = fieldVal
 	case Function:
 		params := make([]interface{}, len(column.params))
 		for i, param := range column.params {
-			paramVal, err := readColumnValue(document, data, param)
+			paramVal, err := readColumnValue(document, data, param, env)
 			if err != nil {
 				return nil, err
 			}
@@ -195,25 +458,11 @@ func readColumnValue(document *firestore.DocumentSnapshot, data *map[string]inte
 		val = funcVal
 		break
 	case Expr:
-		evalExpr, err := govaluate.NewEvaluableExpressionWithFunctions(column.field, support.GetEvalFunctions())
-		if err != nil {
-			return nil, fmt.Errorf("couldn't parse expression %s while reading: %v", column.field, err)
-		}
-
-		params := map[string]interface{}{}
-		for _, param := range column.params {
-			paramVal, err := readColumnValue(document, data, param)
-			if err != nil {
-				return nil, err
-			}
-			params[param.field] = paramVal
-		}
-
-		exprResult, err := evalExpr.Evaluate(params)
+		exprResult, err := column.eval(document, *data, env)
 		if err != nil {
-			return nil, fmt.Errorf("couldn't evauluate expression %s: %v", column.field, err)
+			return nil, fmt.Errorf("couldn't evaluate expression %s: %v", column.field, err)
 		}
-		return exprResult, err
+		return exprResult, nil
 	}
 	return val, nil
 }
@@ -229,33 +478,51 @@ const (
 
 type selectColumn struct {
 	field   string
+	path    firestore.FieldPath
 	alias   string
 	colType ColumnType
 	params  []*selectColumn
+	// expression compiled from field of Expr columns
+	eval evaluator
 }
 
-func (sel *SelectStatement) selectFields(fQuery firestore.Query, sQuery *sqlparser.Select) (firestore.Query, []*selectColumn, error) {
-	qSelects := sQuery.SelectExprs
-
-	columns, err := sel.collectSelectColumns(qSelects)
-	if err != nil {
-		return fQuery, nil, err
+// newFieldColumn returns a column reading the field at the path.
+func newFieldColumn(path firestore.FieldPath, alias string) *selectColumn {
+	return &selectColumn{
+		field:   fieldPathString(path),
+		path:    path,
+		alias:   alias,
+		colType: Field,
 	}
+}
 
+func (sel *SelectStatement) selectFields(fQuery firestore.Query, columns []*selectColumn) firestore.Query {
 	selects := sel.collectSelectFields(columns)
 	if len(selects) > 0 {
-		fQuery = fQuery.Select(selects...)
+		// Fields of client side filters and order must be read too
+		selects = append(selects, sel.collectSelectFields(sel.clientFilters)...)
+		for _, order := range sel.clientOrder {
+			selects = append(selects, sel.collectSelectFields([]*selectColumn{order.column})...)
+		}
+		// Values of order fields are needed for page tokens
+		for _, order := range sel.cursorOrders {
+			if !isDocumentID(order.path) {
+				selects = append(selects, order.path)
+			}
+		}
+		fQuery = fQuery.SelectPaths(selects...)
 	}
-	return fQuery, columns, nil
+	sel.projection = selects
+	return fQuery
 }
 
-func (sel *SelectStatement) collectSelectFields(columns []*selectColumn) []string {
-	var fields []string
+func (sel *SelectStatement) collectSelectFields(columns []*selectColumn) []firestore.FieldPath {
+	var fields []firestore.FieldPath
 loop:
 	for _, col := range columns {
 		switch col.colType {
 		case Field:
-			fields = append(fields, col.field)
+			fields = append(fields, col.path)
 			break
 		case Function:
 			paramFields := sel.collectSelectFields(col.params)
@@ -267,7 +534,7 @@ loop:
 			break
 		case Star:
 			// Don't select fields on firestore.Query to return all fields
-			fields = []string{}
+			fields = []firestore.FieldPath{}
 			break loop
 		}
 
@@ -281,24 +548,28 @@ func (sel *SelectStatement) collectSelectColumns(qSelects sqlparser.SelectExprs)
 	for _, qSelect := range qSelects {
 		switch qSelect := qSelect.(type) {
 		case *sqlparser.StarExpr:
-			columns = append(columns, &selectColumn{
+			column := &selectColumn{
 				field:   "*",
 				colType: Star,
-			})
+			}
+			if qualifier := qSelect.TableName.Name.String(); qualifier != "" && sel.join != nil {
+				// fields of the joined document
+				column.path = firestore.FieldPath{qualifier}
+			}
+			columns = append(columns, column)
 			break
 		case *sqlparser.AliasedExpr:
 			alias := qSelect.As.String()
-			if alias == "" {
-				alias = sqlparser.String(qSelect.Expr)
-			}
 			switch colExpr := qSelect.Expr.(type) {
 			case *sqlparser.ColName:
-				field := colExpr.Name.String()
-				columns = append(columns, &selectColumn{
-					field:   field,
-					alias:   alias,
-					colType: Field,
-				})
+				fieldPath, err := colFieldPath(colExpr)
+				if err != nil {
+					return nil, err
+				}
+				if alias == "" {
+					alias = fieldPathString(fieldPath)
+				}
+				columns = append(columns, newFieldColumn(fieldPath, alias))
 				break
 			//case *sqlparser.FuncExpr:
 			//	name := colExpr.Name.String()
@@ -322,27 +593,14 @@ func (sel *SelectStatement) collectSelectColumns(qSelects sqlparser.SelectExprs)
 			//	})
 			//	break
 			default:
-				expr := sqlparser.String(qSelect.Expr)
-				evalExpr, err := govaluate.NewEvaluableExpressionWithFunctions(expr, support.GetEvalFunctions())
+				column, err := sel.exprColumn(qSelect.Expr)
 				if err != nil {
-					return nil, fmt.Errorf("couldn't parse expression %s: %v", expr, err)
+					return nil, err
 				}
-
-				var fields []*selectColumn
-				for _, token := range evalExpr.Tokens() {
-					if token.Kind == govaluate.VARIABLE {
-						fields = append(fields, &selectColumn{
-							field:   token.Value.(string),
-							colType: Field,
-						})
-					}
+				if alias != "" {
+					column.alias = alias
 				}
-				columns = append(columns, &selectColumn{
-					field:   expr,
-					alias:   alias,
-					colType: Expr,
-					params:  fields,
-				})
+				columns = append(columns, column)
 			}
 			break
 		}
@@ -351,138 +609,135 @@ func (sel *SelectStatement) collectSelectColumns(qSelects sqlparser.SelectExprs)
 	return columns, nil
 }
 
-func (sel *SelectStatement) addWhere(fQuery firestore.Query, sQuery *sqlparser.Select) (firestore.Query, error) {
-	var err error
-	qWhere := sQuery.Where
-	if qWhere != nil {
-		if qWhere.Type == sqlparser.WhereStr {
-			fQuery, err = sel.addWhereExpr(fQuery, sQuery, qWhere.Expr)
-			if err != nil {
-				return fQuery, err
+// lookupDocumentRefs returns references of documents to read directly when
+// WHERE clause is only a __name__ equality or IN condition and result isn't ordered.
+// Returns nil when documents must be queried.
+func (sel *SelectStatement) lookupDocumentRefs(sQuery *sqlparser.Select) ([]*firestore.DocumentRef, error) {
+	if sQuery.Where == nil || sQuery.Where.Type != sqlparser.WhereStr || len(sQuery.OrderBy) > 0 {
+		return nil, nil
+	}
+	expr, ok := sQuery.Where.Expr.(*sqlparser.ComparisonExpr)
+	if !ok || (expr.Operator != sqlparser.EqualStr && expr.Operator != sqlparser.InStr) {
+		return nil, nil
+	}
+	colName, ok := expr.Left.(*sqlparser.ColName)
+	if !ok {
+		return nil, nil
+	}
+	if fieldPath, err := colFieldPath(colName); err != nil || !isDocumentID(fieldPath) {
+		return nil, err
+	}
+	if hasBindVars(expr.Right) {
+		// queried by the __name__ filter with values of the parameters
+		return nil, nil
+	}
+	val, err := sel.getValueFromExpr(expr.Right)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := sel.toDocumentRefs(val)
+	if err != nil {
+		return nil, err
+	}
+	switch refs := refs.(type) {
+	case *firestore.DocumentRef:
+		return []*firestore.DocumentRef{refs}, nil
+	case []interface{}:
+		var docRefs []*firestore.DocumentRef
+		seen := map[string]bool{}
+		for _, ref := range refs {
+			docRef := ref.(*firestore.DocumentRef)
+			if !seen[docRef.Path] {
+				seen[docRef.Path] = true
+				docRefs = append(docRefs, docRef)
 			}
-		} else {
-			return fQuery, fmt.Errorf("unsupported WHERE type: %s", qWhere.Type)
 		}
+		return docRefs, nil
 	}
-	return fQuery, nil
+	return nil, nil
 }
 
-func (sel *SelectStatement) addWhereExpr(fQuery firestore.Query, sQuery *sqlparser.Select, expr sqlparser.Expr) (firestore.Query, error) {
-	var err error
-	switch expr := expr.(type) {
-	case *sqlparser.AndExpr:
-		fQuery, err = sel.addWhereExpr(fQuery, sQuery, expr.Left)
-		if err != nil {
-			return fQuery, err
-		}
-		fQuery, err = sel.addWhereExpr(fQuery, sQuery, expr.Right)
-		if err != nil {
-			return fQuery, err
+// getAll reads documents by references in a single batch, skipping missing documents.
+func (sel *SelectStatement) getAll(docRefs []*firestore.DocumentRef) (documentIterator, error) {
+	limit := sel.rowLimit
+	start := time.Now()
+	snapshots, err := sel.fireClient.GetAll(context.Background(), docRefs)
+	if err != nil {
+		return nil, util.NewFirestoreError(err)
+	}
+	if sel.stats != nil {
+		sel.stats.fetchTime += time.Since(start)
+		// Looking up missing documents is a read as well
+		sel.stats.documentsRead += len(snapshots)
+	}
+	var documents []*firestore.DocumentSnapshot
+	for _, snapshot := range snapshots {
+		if !snapshot.Exists() {
+			continue
 		}
-	case *sqlparser.ComparisonExpr:
-		val, err := sel.getValueFromExpr(expr.Right)
-		if err != nil {
-			return fQuery, err
+		if limit > 0 && len(documents) == limit {
+			break
 		}
-		fQuery = fQuery.Where(expr.Left.(*sqlparser.ColName).Name.String(),
-			sel.getCompareOperator(expr.Operator), val)
-	default:
-		return fQuery, fmt.Errorf("unsupported WHERE clause: %s", sqlparser.String(expr))
+		documents = append(documents, snapshot)
 	}
-	return fQuery, nil
+	return &documentSliceIterator{documents: documents}, nil
 }
 
-func (sel *SelectStatement) getCompareOperator(op string) string {
-	switch op {
-	case sqlparser.EqualStr:
-		return "=="
-	case sqlparser.NotInStr:
-		return "not-in"
-	}
-	return op
+// documentSliceIterator iterates over documents already read.
+type documentSliceIterator struct {
+	documents []*firestore.DocumentSnapshot
 }
 
-func (sel *SelectStatement) getValueFromExpr(valExpr sqlparser.Expr) (interface{}, error) {
-	switch valExpr := valExpr.(type) {
-	case sqlparser.BoolVal:
-		return valExpr, nil
-	case *sqlparser.SQLVal:
-		switch valExpr.Type {
-		case sqlparser.IntVal:
-			val, err := strconv.Atoi(string(valExpr.Val))
-			if err != nil {
-				return nil, err
-			}
-			return val, nil
-		case sqlparser.FloatVal:
-			val, err := strconv.ParseFloat(string(valExpr.Val), 64)
-			if err != nil {
-				return nil, err
-			}
-			return val, nil
-		default:
-			return string(valExpr.Val), nil
-		}
-	case sqlparser.ValTuple:
-		values := make([]interface{}, len(valExpr))
-		for idx, expr := range valExpr {
-			val, err := sel.getValueFromExpr(expr)
-			if err != nil {
-				return nil, err
-			}
-			values[idx] = val
-		}
-		return values, nil
-	case *sqlparser.ParenExpr:
-		return sel.getValueFromExpr(valExpr.Expr)
+func (it *documentSliceIterator) Next() (*firestore.DocumentSnapshot, error) {
+	if len(it.documents) == 0 {
+		return nil, iterator.Done
 	}
-	return nil, nil
+	document := it.documents[0]
+	it.documents = it.documents[1:]
+	return document, nil
 }
 
 func (sel *SelectStatement) addLimit(fQuery firestore.Query, sQuery *sqlparser.Select) (firestore.Query, error) {
-	if sQuery.Limit != nil {
+	rows := sel.context.DefaultLimit
+	if sel.pageSize > 0 {
+		rows = sel.pageSize
+	} else if sQuery.Limit != nil {
 		// Offset not supported by Firestore
-		rows, err := sel.getValueFromExpr(sQuery.Limit.Rowcount)
+		var err error
+		rows, err = sel.limitRows(sQuery.Limit)
 		if err != nil {
 			return fQuery, err
 		}
-		fQuery = fQuery.Limit(rows.(int))
-	} else if sel.context.DefaultLimit > 0 {
-		fQuery = fQuery.Limit(sel.context.DefaultLimit)
+	}
+	if rows > 0 {
+		sel.rowLimit = rows
+		fQuery = sel.limit(fQuery, rows)
 	}
 	return fQuery, nil
 }
 
-func (sel *SelectStatement) addOrderBy(fQuery firestore.Query, sQuery *sqlparser.Select) (firestore.Query, error) {
-	sOrders := sQuery.OrderBy
-	for _, sOrder := range sOrders {
-		column := sOrder.Expr.(*sqlparser.ColName).Name.String()
-		direction := firestore.Asc
-		if sOrder.Direction == sqlparser.DescScr {
-			direction = firestore.Desc
-		}
-		fQuery = fQuery.OrderBy(column, direction)
+// limitRows returns the number of rows in LIMIT clause.
+func (sel *SelectStatement) limitRows(limit *sqlparser.Limit) (int, error) {
+	rowCount := sqlparser.String(limit.Rowcount)
+	if hasBindVars(limit.Rowcount) {
+		return 0, util.NewUnsupportedError(util.CodeUnsupportedClause, rowCount, "LIMIT doesn't accept parameters, got %s", rowCount)
 	}
-	return fQuery, nil
+	rows, err := sel.getValueFromExpr(limit.Rowcount)
+	if err != nil {
+		return 0, err
+	}
+	if rows, ok := rows.(int); ok && rows >= 0 {
+		return rows, nil
+	}
+	return 0, util.NewParseError(util.CodeInvalidArgument, rowCount, "LIMIT expects a non-negative integer, got %s", rowCount)
 }
 
-func (sel *SelectStatement) newFireClient() (*firestore.Client, error) {
-	ctx := context.Background()
-
-	var firestoreOptions []option.ClientOption
-	if len(sel.context.ServiceAccount) > 0 {
-		if !json.Valid([]byte(sel.context.ServiceAccount)) {
-			return nil, errors.New("invalid service account, it is expected to be a JSON")
-		}
-
-		creds, err := google.CredentialsFromJSON(ctx, []byte(sel.context.ServiceAccount),
-			vkit.DefaultAuthScopes()...,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("ServiceAccount: %v", err)
-		}
-		firestoreOptions = append(firestoreOptions, option.WithCredentials(creds))
+// limit limits results of the query, on the client side when
+// documents are filtered or sorted on the client side too.
+func (sel *SelectStatement) limit(fQuery firestore.Query, limit int) firestore.Query {
+	if len(sel.clientFilters) > 0 || len(sel.clientOrder) > 0 {
+		sel.clientLimit = limit
+		return fQuery
 	}
-
-	return firestore.NewClient(ctx, sel.context.ProjectId, firestoreOptions...)
+	return fQuery.Limit(limit)
 }
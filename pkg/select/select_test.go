@@ -3,10 +3,14 @@ package _select
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/pgollangi/fireql/pkg/support"
 	"github.com/pgollangi/fireql/pkg/util"
+	"github.com/xwb1989/sqlparser"
+	"google.golang.org/genproto/googleapis/type/latlng"
 	"io"
 	"log"
 	"os"
@@ -16,6 +20,7 @@ import (
 	"sync"
 	"syscall"
 	"testing"
+	"time"
 
 	"cloud.google.com/go/firestore"
 )
@@ -84,17 +89,47 @@ var selectTests = []TestExpect{
 		records: [][]interface{}{{float64(6)}},
 	},
 	{
-		query:   "select id from users where `address.city` = 'Glendale' and name = 'Eleanora'",
+		query:   "select id from users where address.city = 'Glendale' and name = 'Eleanora'",
 		columns: []string{"id"},
 		length:  "1",
 		records: [][]interface{}{{float64(10)}},
 	},
 	{
-		query:   "select id > 0 as has_id from users where `address.city` = 'Glendale' and name = 'Eleanora'",
+		query:   "select id, address.city, address.`zip-code` from users where address.`zip-code` = '20001' order by address.city",
+		columns: []string{"id", "address.city", "address.`zip-code`"},
+		length:  "1",
+		records: [][]interface{}{{float64(1), "Washington", "20001"}},
+	},
+	{
+		query:   "select id > 0 as has_id from users where address.city = 'Glendale' and name = 'Eleanora'",
 		columns: []string{"has_id"},
 		length:  "1",
 		records: [][]interface{}{{true}},
 	},
+	{
+		query:   "select id from users where id = 1 or name = 'Eleanora'",
+		columns: []string{"id"},
+		length:  "2",
+		records: [][]interface{}{{float64(1)}, {float64(10)}},
+	},
+	{
+		query:   "select id from users where age is null and id <= 2",
+		columns: []string{"id"},
+		length:  "2",
+		records: [][]interface{}{{float64(1)}, {float64(2)}},
+	},
+	{
+		query:   "select users.email from users where users.id = 20",
+		columns: []string{"email"},
+		length:  "1",
+		records: [][]interface{}{{"aeatockj@psu.edu"}},
+	},
+	{
+		query:   "select u.email from users u where u.address.city = 'Glendale' and name = 'Eleanora'",
+		columns: []string{"email"},
+		length:  "1",
+		records: [][]interface{}{{"umcgourty9@jalbum.net"}},
+	},
 	{
 		query:   "select __name__ from users where id = 1",
 		columns: []string{"__name__"},
@@ -107,6 +142,174 @@ var selectTests = []TestExpect{
 		length:  "1",
 		records: [][]interface{}{{float64(21), nil, "ckensleyk"}},
 	},
+	{
+		query:   "select id from users where __name__ = '5'",
+		columns: []string{"id"},
+		length:  "1",
+		records: [][]interface{}{{float64(5)}},
+	},
+	{
+		query:   "select id from users where __name__ in ('1', '2', '2', '99')",
+		columns: []string{"id"},
+		length:  "2",
+	},
+	{
+		query:   "select id from users where __name__ in ('1', '2') order by id desc",
+		columns: []string{"id"},
+		length:  "2",
+		records: [][]interface{}{{float64(2)}, {float64(1)}},
+	},
+	{
+		query:   "select id from users where __name__ > '8'",
+		columns: []string{"id"},
+		length:  "1",
+		records: [][]interface{}{{float64(9)}},
+	},
+	{
+		query:   "select id from users where tags contains 'admin'",
+		columns: []string{"id"},
+		length:  "1",
+		records: [][]interface{}{{float64(5)}},
+	},
+	{
+		query:   "select id from users where 'beta' = ANY(tags) order by id",
+		columns: []string{"id"},
+		length:  "2",
+		records: [][]interface{}{{float64(5)}, {float64(8)}},
+	},
+	{
+		query:   "select id from users where tags contains any ('admin', 'beta') order by id",
+		columns: []string{"id"},
+		length:  "2",
+		records: [][]interface{}{{float64(5)}, {float64(8)}},
+	},
+	{
+		query:   "select id from users where name like 'Ter%' order by name",
+		columns: []string{"id"},
+		length:  "2",
+		records: [][]interface{}{{float64(3)}, {float64(1)}},
+	},
+	{
+		query:   "select id from users where name like 'Terry'",
+		columns: []string{"id"},
+		length:  "1",
+		records: [][]interface{}{{float64(1)}},
+	},
+	{
+		query:   "select id from users where email like '%.edu' order by id",
+		columns: []string{"id"},
+		length:  "3",
+		records: [][]interface{}{{float64(5)}, {float64(7)}, {float64(20)}},
+	},
+	{
+		query:   "select id from users where email like '%.edu' order by id limit 2",
+		columns: []string{"id"},
+		length:  "2",
+		records: [][]interface{}{{float64(5)}, {float64(7)}},
+	},
+	{
+		query:   "select id from users where name ilike 'ter%'",
+		columns: []string{"id"},
+		length:  "2",
+	},
+	{
+		query:   "select id from users where username regexp '^a'",
+		columns: []string{"id"},
+		length:  "3",
+	},
+	{
+		query:   "select id from users where id between 3 and 5",
+		columns: []string{"id"},
+		length:  "3",
+	},
+	{
+		query:   "select id from users where id not between 3 and 20",
+		columns: []string{"id"},
+		length:  "3",
+	},
+	{
+		query:   "select id, item from `users/1/orders` order by id",
+		columns: []string{"id", "item"},
+		length:  "2",
+		records: [][]interface{}{{float64(1), "Keyboard"}, {float64(2), "Mouse"}},
+	},
+	{
+		query:   "select id from `[orders]`",
+		columns: []string{"id"},
+		length:  "5",
+	},
+	{
+		// orders of users/10 aren't under users/1
+		query:   "select id from `users/1/[orders]`",
+		columns: []string{"id"},
+		length:  "2",
+		records: [][]interface{}{{float64(1)}, {float64(2)}},
+	},
+	{
+		query:   "select id from `users/10/[orders]`",
+		columns: []string{"id"},
+		length:  "1",
+		records: [][]interface{}{{float64(5)}},
+	},
+	{
+		query:   "select id from `users/1/[items]`",
+		columns: []string{"id"},
+		length:  "1",
+		records: [][]interface{}{{int64(1)}},
+	},
+	{
+		query:   "select id, address.city as city from users where id in (1, 2, 3) order by city",
+		columns: []string{"id", "city"},
+		length:  "3",
+		records: [][]interface{}{{float64(3), "Grass Valley"}, {float64(2), "Louisville"}, {float64(1), "Washington"}},
+	},
+	{
+		query:   "select id, LENGTH(name) as len from users where id <= 3 order by len desc, id limit 2",
+		columns: []string{"id", "len"},
+		length:  "2",
+		records: [][]interface{}{{float64(2), float64(7)}, {float64(3), float64(7)}},
+	},
+	{
+		query:   "select id from users where id in (4, 5, 8) order by tags nulls last, 1",
+		columns: []string{"id"},
+		length:  "3",
+		records: [][]interface{}{{float64(5)}, {float64(8)}, {float64(4)}},
+	},
+	{
+		query:   "select id from users where 19 < id",
+		columns: []string{"id"},
+		length:  "2",
+	},
+	{
+		query:   "select id from users where LENGTH(tags) > 1",
+		columns: []string{"id"},
+		length:  "1",
+		records: [][]interface{}{{float64(5)}},
+	},
+	{
+		query:   "select id, UPPER(name) as name, SUBSTR(email, 1, 3) as prefix from users where STARTS_WITH(name, 'Ter') and id = 1",
+		columns: []string{"id", "name", "prefix"},
+		length:  "1",
+		records: [][]interface{}{{float64(1), "TERRY", "atu"}},
+	},
+	{
+		query:   "select o.id, o.item, u.name from `[orders]` o join users u on o.user = u.id order by o.id",
+		columns: []string{"o.id", "o.item", "u.name"},
+		length:  "5",
+		records: [][]interface{}{{float64(1), "Keyboard", "Terry"}, {float64(2), "Mouse", "Terry"}, {float64(3), "Monitor", "Sheldon"}, {float64(4), "Keyboard", "Terrill"}, {float64(5), "Cable", "Eleanora"}},
+	},
+	{
+		query:   "select u.id, o.item from users u left join `[orders]` o on u.id = o.user where u.id between 2 and 4 order by u.id",
+		columns: []string{"u.id", "o.item"},
+		length:  "3",
+		records: [][]interface{}{{float64(2), "Monitor"}, {float64(3), "Keyboard"}, {float64(4), nil}},
+	},
+	{
+		query:   "select u.name as name, o.quantity * o.price as total from `[orders]` o join users u on u.id = o.user where o.item = 'Keyboard' and u.name like 'Terr%' order by total desc limit 1",
+		columns: []string{"name", "total"},
+		length:  "1",
+		records: [][]interface{}{{"Terrill", 149.97}},
+	},
 }
 
 func newFirestoreTestClient(ctx context.Context) *firestore.Client {
@@ -193,6 +396,18 @@ func TestMain(m *testing.M) {
 		users.Doc(fmt.Sprintf("%v", user["id"].(float64))).Set(ctx, user)
 	}
 
+	ordersDataRaw, _ := os.ReadFile("../../test/data/orders.json")
+	var ordersData []map[string]interface{}
+	json.Unmarshal(ordersDataRaw, &ordersData)
+
+	for _, order := range ordersData {
+		users.Doc(fmt.Sprintf("%v", order["user"].(float64))).Collection("orders").
+			Doc(fmt.Sprintf("%v", order["id"].(float64))).Set(ctx, order)
+	}
+	// items under collections whose IDs sort after U+F8FF
+	users.Doc("1").Collection("\U0001F6D2").Doc("a").Collection("items").Doc("1").Set(ctx, map[string]interface{}{"id": 1})
+	users.Doc("10").Collection("\U0001F6D2").Doc("a").Collection("items").Doc("2").Set(ctx, map[string]interface{}{"id": 2})
+
 	//selectTests = append(selectTests, TestExpect{query: "select * from users", expected: usersData})
 	// now it's running, we can run our unit tests
 	result = m.Run()
@@ -225,6 +440,152 @@ func TestSelectQueries(t *testing.T) {
 	}
 }
 
+func TestExecutePage(t *testing.T) {
+	query := "select id from users where id <= 5 order by id desc"
+	var pages [][][]interface{}
+	pageToken := ""
+	for {
+		result, err := New(&util.Context{ProjectId: "test"}, query).ExecutePage(2, pageToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages = append(pages, result.Records)
+		if result.NextPageToken == "" || len(pages) > 5 {
+			break
+		}
+		pageToken = result.NextPageToken
+	}
+	expected := [][][]interface{}{{{float64(5)}, {float64(4)}}, {{float64(3)}, {float64(2)}}, {{float64(1)}}}
+	if !cmp.Equal(pages, expected) {
+		t.Errorf("ExecutePage(%s): expected %v, actual %v", query, expected, pages)
+	}
+
+	first, err := New(&util.Context{ProjectId: "test"}, "select id from users where id > 3 limit 2").Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err := New(&util.Context{ProjectId: "test"}, fmt.Sprintf("select id from users where id > 3 limit 2 after '%s'", first.NextPageToken)).Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(first.Records, [][]interface{}{{float64(4)}, {float64(5)}}) || !cmp.Equal(next.Records, [][]interface{}{{float64(6)}, {float64(7)}}) {
+		t.Errorf("AFTER: unexpected pages %v, %v", first.Records, next.Records)
+	}
+}
+
+func TestPreparedVolatileFunctions(t *testing.T) {
+	ctx := context.Background()
+	client := newFirestoreTestClient(ctx)
+	defer client.Close()
+	expiresAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := client.Doc("sessions/s1").Set(ctx, map[string]interface{}{"expires_at": expiresAt}); err != nil {
+		t.Fatal(err)
+	}
+	// CLOCK() reads the time set by the test, like NOW() reads the current time
+	var now time.Time
+	functions := support.NewFunctions()
+	if err := functions.RegisterVolatile("CLOCK", func([]interface{}) (interface{}, error) { return now, nil }, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	stmt, err := New(&util.Context{ProjectId: "test", Functions: functions}, "select __name__ from sessions where expires_at > CLOCK()").Prepare()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	for _, tt := range []struct {
+		now      time.Time
+		expected int
+	}{{now: expiresAt.Add(-time.Hour), expected: 1}, {now: expiresAt.Add(time.Hour), expected: 0}} {
+		now = tt.now
+		result, err := stmt.Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Records) != tt.expected {
+			t.Errorf("at %v: expected %d unexpired sessions, actual %v", tt.now, tt.expected, result.Records)
+		}
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected error
+		code     util.ErrorCode
+	}{
+		{query: "select * form users", expected: &util.ParseError{}, code: util.CodeSyntaxError},
+		{query: "select id from users union select id from users", expected: &util.UnsupportedError{}, code: util.CodeUnsupportedStatement},
+		{query: "select name from users group by name", expected: &util.UnsupportedError{}, code: util.CodeUnsupportedClause},
+		{query: "select * from users limit 'x'", expected: &util.ParseError{}, code: util.CodeInvalidArgument},
+		{query: "select * from users where FOO(id)", expected: &util.UnsupportedError{}, code: util.CodeUnsupportedFunction},
+		{query: "select * from users where id in (1, 2) and name not in ('a')", expected: &util.UnsupportedError{}, code: util.CodeQueryLimitation},
+		{query: "select FOO(id) from users", expected: &util.UnsupportedError{}, code: util.CodeUnsupportedFunction},
+		{query: "select * from users where LENGTH(name, email) > 1", expected: &util.ParseError{}, code: util.CodeInvalidArgument},
+		{query: "select * from users where name like 'a!_%' escape '!!'", expected: &util.ParseError{}, code: util.CodeInvalidArgument},
+		{query: "select * from users where __name__ = 'orders/x'", expected: &util.ParseError{}, code: util.CodeInvalidArgument},
+		{query: "select * from users where __name__ in ('1', 'users/1/orders/1')", expected: &util.ParseError{}, code: util.CodeInvalidArgument},
+	}
+	for _, tt := range tests {
+		_, err := New(&util.Context{ProjectId: "test"}, tt.query).Execute()
+		var code util.ErrorCode
+		switch expected := tt.expected.(type) {
+		case *util.ParseError:
+			if errors.As(err, &expected) {
+				code = expected.Code
+			}
+		case *util.UnsupportedError:
+			if errors.As(err, &expected) {
+				code = expected.Code
+			}
+		}
+		if code != tt.code {
+			t.Errorf("%s: expected %T with code %s, actual %v", tt.query, tt.expected, tt.code, err)
+		}
+	}
+}
+
 func first(n int, _ error) int {
 	return n
 }
+
+func TestTypedLiteralValues(t *testing.T) {
+	sel := New(&util.Context{ProjectId: "test"}, "")
+	sel.fireClient = newFirestoreTestClient(context.Background())
+	defer sel.fireClient.Close()
+
+	tests := []struct {
+		literal  string
+		expected interface{}
+	}{
+		{"TIMESTAMP '2024-01-01T10:00:00Z'", time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)},
+		{"DATE '2024-01-01'", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"TIMESTAMP '2024-01-08' - INTERVAL 7 DAY", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"GEOPOINT(-33.86, 151.2)", &latlng.LatLng{Latitude: -33.86, Longitude: 151.2}},
+		{"REF('users/abc')", sel.fireClient.Doc("users/abc")},
+	}
+	for _, tt := range tests {
+		stmt, err := sqlparser.Parse(rewriteQuery("select * from users where a = " + tt.literal))
+		if err != nil {
+			t.Errorf("%s: %v", tt.literal, err)
+			continue
+		}
+		actual, err := sel.getValueFromExpr(stmt.(*sqlparser.Select).Where.Expr.(*sqlparser.ComparisonExpr).Right)
+		if err != nil {
+			t.Errorf("%s: %v", tt.literal, err)
+		} else if !cmp.Equal(tt.expected, actual, cmpopts.IgnoreUnexported(latlng.LatLng{}),
+			cmp.Comparer(func(a, b *firestore.DocumentRef) bool { return a.Path == b.Path })) {
+			t.Errorf("%s: expected %v, actual %v", tt.literal, tt.expected, actual)
+		}
+	}
+
+	stmt, _ := sqlparser.Parse("select * from users where a = now()")
+	val, err := sel.getValueFromExpr(stmt.(*sqlparser.Select).Where.Expr.(*sqlparser.ComparisonExpr).Right)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// NOW() is computed on each execution
+	now, err := resolveValue(val, nil)
+	if _, ok := now.(time.Time); !ok || err != nil || !hasBoundValue(val) {
+		t.Errorf("NOW(): expected a timestamp computed on execution, actual %v, %v", now, err)
+	}
+}
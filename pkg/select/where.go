@@ -0,0 +1,579 @@
+package _select
+
+import (
+	"cloud.google.com/go/firestore"
+	"github.com/pgollangi/fireql/pkg/support"
+	"github.com/pgollangi/fireql/pkg/util"
+	"github.com/xwb1989/sqlparser"
+	"google.golang.org/genproto/googleapis/type/latlng"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// queryFilter is a filter of the Firestore query.
+type queryFilter struct {
+	path  firestore.FieldPath
+	op    string
+	value interface{}
+}
+
+// Firestore limits on number of values in in, not-in and array-contains-any filters
+const (
+	maxDisjunctionValues = 30
+	maxNotInValues       = 10
+)
+
+func (sel *SelectStatement) addWhere(fQuery firestore.Query, sQuery *sqlparser.Select) (firestore.Query, error) {
+	var err error
+	qWhere := sQuery.Where
+	if qWhere != nil {
+		if qWhere.Type == sqlparser.WhereStr {
+			fQuery, err = sel.addWhereExpr(fQuery, sQuery, qWhere.Expr)
+			if err != nil {
+				return fQuery, err
+			}
+		} else {
+			return fQuery, util.NewUnsupportedError(util.CodeUnsupportedClause, qWhere.Type, "unsupported WHERE type: %s", qWhere.Type)
+		}
+	}
+	return fQuery, nil
+}
+
+func (sel *SelectStatement) addWhereExpr(fQuery firestore.Query, sQuery *sqlparser.Select, expr sqlparser.Expr) (firestore.Query, error) {
+	var err error
+	switch expr := expr.(type) {
+	case *sqlparser.AndExpr:
+		fQuery, err = sel.addWhereExpr(fQuery, sQuery, expr.Left)
+		if err != nil {
+			return fQuery, err
+		}
+		fQuery, err = sel.addWhereExpr(fQuery, sQuery, expr.Right)
+		if err != nil {
+			return fQuery, err
+		}
+	case *sqlparser.ComparisonExpr:
+		expr = normalizeComparison(expr)
+		if anyFunc, ok := expr.Right.(*sqlparser.FuncExpr); ok && anyFunc.Name.Lowered() == "any" {
+			// 'x' = ANY(tags)
+			if expr.Operator != sqlparser.EqualStr {
+				return fQuery, util.NewUnsupportedError(util.CodeUnsupportedExpression, sqlparser.String(expr), "only = is supported with ANY: %s", sqlparser.String(expr))
+			}
+			return sel.addArrayContainsExpr(fQuery, "ANY", anyFunc.Exprs, expr.Left, "array-contains")
+		}
+		switch expr.Operator {
+		case sqlparser.LikeStr:
+			return sel.addLikeExpr(fQuery, expr)
+		case sqlparser.NotLikeStr, sqlparser.RegexpStr, sqlparser.NotRegexpStr:
+			return fQuery, sel.addClientFilter(expr)
+		}
+		colName, ok := expr.Left.(*sqlparser.ColName)
+		if !ok || referencesFields(expr.Right) {
+			// Firestore filters compare a field with values
+			return fQuery, sel.addClientFilter(expr)
+		}
+		field, err := colFieldPath(colName)
+		if err != nil {
+			return fQuery, err
+		}
+		val, err := sel.getValueFromExpr(expr.Right)
+		if err != nil {
+			return fQuery, err
+		}
+		fQuery, err = sel.addFilter(fQuery, field, sel.getCompareOperator(expr.Operator), val)
+		if err != nil {
+			return fQuery, err
+		}
+	case *sqlparser.RangeCond:
+		colName, ok := expr.Left.(*sqlparser.ColName)
+		if expr.Operator != sqlparser.BetweenStr || !ok || referencesFields(expr.From) || referencesFields(expr.To) {
+			return fQuery, sel.addClientFilter(expr)
+		}
+		field, err := colFieldPath(colName)
+		if err != nil {
+			return fQuery, err
+		}
+		// BETWEEN is a closed range
+		from, err := sel.getValueFromExpr(expr.From)
+		if err != nil {
+			return fQuery, err
+		}
+		to, err := sel.getValueFromExpr(expr.To)
+		if err != nil {
+			return fQuery, err
+		}
+		fQuery, err = sel.addFilter(fQuery, field, ">=", from)
+		if err != nil {
+			return fQuery, err
+		}
+		fQuery, err = sel.addFilter(fQuery, field, "<=", to)
+		if err != nil {
+			return fQuery, err
+		}
+	case *sqlparser.FuncExpr:
+		var syntax, op string
+		switch expr.Name.Lowered() {
+		case "array_contains":
+			// tags CONTAINS 'x'
+			syntax, op = "CONTAINS", "array-contains"
+			if !isArrayContainsFilter(expr) {
+				// e.g. ARRAY_CONTAINS(SPLIT(path, '/'), 'x')
+				return fQuery, sel.addClientFilter(expr)
+			}
+		case "array_contains_any":
+			// tags CONTAINS ANY ('x', 'y')
+			syntax, op = "CONTAINS ANY", "array-contains-any"
+		default:
+			// Boolean functions, e.g. STARTS_WITH(name, 'a')
+			return fQuery, sel.addClientFilter(expr)
+		}
+		if len(expr.Exprs) != 2 {
+			return fQuery, util.NewParseError(util.CodeInvalidArgument, sqlparser.String(expr), "%s expects an array field and a value: %s", syntax, sqlparser.String(expr))
+		}
+		valArg, ok := expr.Exprs[1].(*sqlparser.AliasedExpr)
+		if !ok {
+			return fQuery, util.NewParseError(util.CodeInvalidArgument, sqlparser.String(expr), "%s expects an array field and a value: %s", syntax, sqlparser.String(expr))
+		}
+		return sel.addArrayContainsExpr(fQuery, syntax, expr.Exprs[:1], valArg.Expr, op)
+	default:
+		// conditions Firestore can't evaluate, e.g. OR, NOT and IS NULL
+		return fQuery, sel.addClientFilter(expr)
+	}
+	return fQuery, nil
+}
+
+// reversedOperators maps comparison operators to their equivalent with operands swapped.
+var reversedOperators = map[string]string{
+	sqlparser.EqualStr:         sqlparser.EqualStr,
+	sqlparser.NotEqualStr:      sqlparser.NotEqualStr,
+	sqlparser.LessThanStr:      sqlparser.GreaterThanStr,
+	sqlparser.LessEqualStr:     sqlparser.GreaterEqualStr,
+	sqlparser.GreaterThanStr:   sqlparser.LessThanStr,
+	sqlparser.GreaterEqualStr:  sqlparser.LessEqualStr,
+	sqlparser.NullSafeEqualStr: sqlparser.NullSafeEqualStr,
+}
+
+// normalizeComparison swaps operands of comparisons with the field on the right,
+// e.g. 5 < age into age > 5.
+func normalizeComparison(expr *sqlparser.ComparisonExpr) *sqlparser.ComparisonExpr {
+	_, leftIsField := expr.Left.(*sqlparser.ColName)
+	_, rightIsField := expr.Right.(*sqlparser.ColName)
+	reversed, ok := reversedOperators[expr.Operator]
+	if leftIsField || !rightIsField || !ok {
+		return expr
+	}
+	return &sqlparser.ComparisonExpr{Operator: reversed, Left: expr.Right, Right: expr.Left, Escape: expr.Escape}
+}
+
+// referencesFields reports whether the expression refers to any document field.
+func referencesFields(expr sqlparser.Expr) bool {
+	found := false
+	_ = sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		if _, ok := node.(*sqlparser.ColName); ok {
+			found = true
+		}
+		return !found, nil
+	}, expr)
+	return found
+}
+
+// addLikeExpr translates LIKE with a prefix pattern, e.g. 'abc%', into a range filter
+// >= 'abc' AND < 'abd', and LIKE without wildcards into an equality filter.
+// Other patterns are evaluated on the client side.
+func (sel *SelectStatement) addLikeExpr(fQuery firestore.Query, expr *sqlparser.ComparisonExpr) (firestore.Query, error) {
+	colName, isField := expr.Left.(*sqlparser.ColName)
+	pattern, isStr := expr.Right.(*sqlparser.SQLVal)
+	if !isField || !isStr || pattern.Type != sqlparser.StrVal {
+		return fQuery, sel.addClientFilter(expr)
+	}
+	field, err := colFieldPath(colName)
+	if err != nil {
+		return fQuery, err
+	}
+	escape, err := likeEscape(expr)
+	if err != nil {
+		return fQuery, err
+	}
+	prefix, rest := likePrefix(string(pattern.Val), escape)
+	if rest == "" {
+		return sel.addFilter(fQuery, field, "==", prefix)
+	}
+	if rest != "%" {
+		return fQuery, sel.addClientFilter(expr)
+	}
+	fQuery, err = sel.addFilter(fQuery, field, ">=", prefix)
+	if err != nil {
+		return fQuery, err
+	}
+	if upperBound, ok := prefixUpperBound(prefix); ok {
+		fQuery, err = sel.addFilter(fQuery, field, "<", upperBound)
+	}
+	return fQuery, err
+}
+
+// addClientFilter adds a condition Firestore can't evaluate, to be evaluated
+// on each document read from Firestore.
+func (sel *SelectStatement) addClientFilter(expr sqlparser.Expr) error {
+	filter, err := sel.exprColumn(expr)
+	if err != nil {
+		return err
+	}
+	sel.clientFilters = append(sel.clientFilters, filter)
+	return nil
+}
+
+// exprColumn returns a column evaluating the expression on each document.
+func (sel *SelectStatement) exprColumn(expr sqlparser.Expr) (*selectColumn, error) {
+	compiler := &exprCompiler{functions: sel.context.Functions}
+	eval, err := compiler.compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	column := &selectColumn{
+		field:   sqlparser.String(expr),
+		alias:   sqlparser.String(expr),
+		colType: Expr,
+		eval:    eval,
+	}
+	sel.derefs = append(sel.derefs, compiler.derefs...)
+	for _, field := range compiler.fields {
+		column.params = append(column.params, newFieldColumn(field, ""))
+	}
+	return column, nil
+}
+
+// isArrayContainsFilter reports whether ARRAY_CONTAINS compares an array field with a value,
+// which Firestore can evaluate.
+func isArrayContainsFilter(funcExpr *sqlparser.FuncExpr) bool {
+	if len(funcExpr.Exprs) != 2 {
+		return false
+	}
+	arrayArg, ok := funcExpr.Exprs[0].(*sqlparser.AliasedExpr)
+	if !ok {
+		return false
+	}
+	valArg, ok := funcExpr.Exprs[1].(*sqlparser.AliasedExpr)
+	if !ok {
+		return false
+	}
+	_, isField := arrayArg.Expr.(*sqlparser.ColName)
+	return isField && !referencesFields(valArg.Expr)
+}
+
+// addArrayContainsExpr adds array-contains or array-contains-any filter on the array field
+// given as the only argument of CONTAINS or ANY.
+func (sel *SelectStatement) addArrayContainsExpr(fQuery firestore.Query, syntax string, arrayArgs sqlparser.SelectExprs, valExpr sqlparser.Expr, op string) (firestore.Query, error) {
+	var field firestore.FieldPath
+	if len(arrayArgs) == 1 {
+		if arg, ok := arrayArgs[0].(*sqlparser.AliasedExpr); ok {
+			if colName, ok := arg.Expr.(*sqlparser.ColName); ok {
+				var err error
+				if field, err = colFieldPath(colName); err != nil {
+					return fQuery, err
+				}
+			}
+		}
+	}
+	if field == nil {
+		return fQuery, util.NewParseError(util.CodeInvalidArgument, sqlparser.String(arrayArgs), "%s expects an array field, got %s", syntax, sqlparser.String(arrayArgs))
+	}
+	val, err := sel.getValueFromExpr(valExpr)
+	if err != nil {
+		return fQuery, err
+	}
+	if _, isList := val.([]interface{}); op == "array-contains-any" && !isList {
+		val = []interface{}{val}
+	}
+	return sel.addFilter(fQuery, field, op, val)
+}
+
+// addFilter validates and adds a filter on the field to the query.
+func (sel *SelectStatement) addFilter(fQuery firestore.Query, field firestore.FieldPath, op string, val interface{}) (firestore.Query, error) {
+	var err error
+	if isDocumentID(field) && !hasBoundValue(val) {
+		// Firestore expects document references to compare document names
+		val, err = sel.toDocumentRefs(val)
+		if err != nil {
+			return fQuery, err
+		}
+	}
+	if err = sel.validateFilter(fieldPathString(field), op, val); err != nil {
+		return fQuery, err
+	}
+	sel.filters = append(sel.filters, queryFilter{path: field, op: op, value: val})
+	if sel.inequalityField == nil && isInequalityOp(op) {
+		sel.inequalityField = field
+	}
+	if hasBoundValue(val) {
+		// added on each execution with values of the parameters or volatile functions
+		return fQuery, nil
+	}
+	return fQuery.WherePath(field, op, val), nil
+}
+
+// validateFilter checks the filter against Firestore query limitations, which are otherwise
+// reported by Firestore only when the query is run.
+// See https://firebase.google.com/docs/firestore/query-data/queries#limitations
+func (sel *SelectStatement) validateFilter(field string, op string, val interface{}) error {
+	switch op {
+	case "in", "not-in", "array-contains-any":
+		values, ok := val.([]interface{})
+		if !ok {
+			return util.NewParseError(util.CodeInvalidArgument, field, `"%s" filter on "%s" expects a list of values`, op, field)
+		}
+		maxValues := maxDisjunctionValues
+		if op == "not-in" {
+			maxValues = maxNotInValues
+		}
+		if len(values) == 0 || len(values) > maxValues {
+			return util.NewUnsupportedError(util.CodeQueryLimitation, field, `"%s" filter on "%s" accepts 1 to %d values, got %d`, op, field, maxValues, len(values))
+		}
+	}
+
+	for _, prevFilter := range sel.filters {
+		prevOp := prevFilter.op
+		switch {
+		case isArrayContainsOp(op) && isArrayContainsOp(prevOp):
+			return util.NewUnsupportedError(util.CodeQueryLimitation, field, "a query can have at most one array-contains or array-contains-any filter")
+		case op == "not-in" && prevOp == "not-in":
+			return util.NewUnsupportedError(util.CodeQueryLimitation, field, "a query can have at most one not-in filter")
+		case op == "not-in" && conflictsWithNotIn(prevOp):
+			return util.NewUnsupportedError(util.CodeQueryLimitation, field, `not-in filter can't be combined with "%s" filter in the same query`, prevOp)
+		case prevOp == "not-in" && conflictsWithNotIn(op):
+			return util.NewUnsupportedError(util.CodeQueryLimitation, field, `not-in filter can't be combined with "%s" filter in the same query`, op)
+		}
+	}
+	return nil
+}
+
+func isInequalityOp(op string) bool {
+	switch op {
+	case "<", "<=", ">", ">=", "!=", "not-in":
+		return true
+	}
+	return false
+}
+
+func isArrayContainsOp(op string) bool {
+	return op == "array-contains" || op == "array-contains-any"
+}
+
+func conflictsWithNotIn(op string) bool {
+	return op == "in" || op == "array-contains-any" || op == "!="
+}
+
+// toDocumentRefs converts document IDs or paths compared with __name__
+// to document references.
+func (sel *SelectStatement) toDocumentRefs(val interface{}) (interface{}, error) {
+	switch val := val.(type) {
+	case string:
+		return sel.collection.DocumentRef(sel.fireClient, val)
+	case []interface{}:
+		refs := make([]interface{}, len(val))
+		for idx, id := range val {
+			ref, err := sel.toDocumentRefs(id)
+			if err != nil {
+				return nil, err
+			}
+			refs[idx] = ref
+		}
+		return refs, nil
+	}
+	return nil, util.NewParseError(util.CodeInvalidArgument, firestore.DocumentID, "__name__ must be compared with document ID or path, got %v", val)
+}
+
+func (sel *SelectStatement) getCompareOperator(op string) string {
+	switch op {
+	case sqlparser.EqualStr:
+		return "=="
+	case sqlparser.NotInStr:
+		return "not-in"
+	}
+	return op
+}
+
+func (sel *SelectStatement) getValueFromExpr(valExpr sqlparser.Expr) (interface{}, error) {
+	if _, isTuple := valExpr.(sqlparser.ValTuple); !isTuple && (hasBindVars(valExpr) || callsVolatile(valExpr, sel.context.Functions)) {
+		return sel.boundValue(valExpr)
+	}
+	switch valExpr := valExpr.(type) {
+	case sqlparser.BoolVal:
+		return valExpr, nil
+	case *sqlparser.SQLVal:
+		switch valExpr.Type {
+		case sqlparser.IntVal:
+			val, err := strconv.Atoi(string(valExpr.Val))
+			if err != nil {
+				return nil, err
+			}
+			return val, nil
+		case sqlparser.FloatVal:
+			val, err := strconv.ParseFloat(string(valExpr.Val), 64)
+			if err != nil {
+				return nil, err
+			}
+			return val, nil
+		default:
+			return string(valExpr.Val), nil
+		}
+	case sqlparser.ValTuple:
+		values := make([]interface{}, len(valExpr))
+		for idx, expr := range valExpr {
+			val, err := sel.getValueFromExpr(expr)
+			if err != nil {
+				return nil, err
+			}
+			values[idx] = val
+		}
+		return values, nil
+	case *sqlparser.ParenExpr:
+		return sel.getValueFromExpr(valExpr.Expr)
+	case *sqlparser.UnaryExpr:
+		val, err := sel.getValueFromExpr(valExpr.Expr)
+		if err != nil {
+			return nil, err
+		}
+		if valExpr.Operator == sqlparser.UMinusStr {
+			switch val := val.(type) {
+			case int:
+				return -val, nil
+			case float64:
+				return -val, nil
+			}
+		}
+		return nil, util.NewUnsupportedError(util.CodeUnsupportedExpression, sqlparser.String(valExpr), "unsupported value: %s", sqlparser.String(valExpr))
+	case *sqlparser.NullVal:
+		return nil, nil
+	case *sqlparser.FuncExpr:
+		return sel.getFuncValue(valExpr)
+	case *sqlparser.BinaryExpr:
+		return sel.getIntervalValue(valExpr)
+	}
+	return nil, util.NewUnsupportedError(util.CodeUnsupportedExpression, sqlparser.String(valExpr), "unsupported value: %s", sqlparser.String(valExpr))
+}
+
+// getFuncValue evaluates functions constructing typed values,
+// e.g. TIMESTAMP('2024-01-01T00:00:00Z'), GEOPOINT(lat, lng) or REF('users/abc'),
+// and registered functions of constant arguments, e.g. TIMESTAMP_SUB(TIMESTAMP('2024-01-08'), 7, 'DAY').
+// Values calling volatile functions, e.g. NOW(), are computed on each execution instead.
+func (sel *SelectStatement) getFuncValue(funcExpr *sqlparser.FuncExpr) (interface{}, error) {
+	name := funcExpr.Name.Lowered()
+	var args []interface{}
+	for _, arg := range funcExpr.Exprs {
+		aliasedArg, ok := arg.(*sqlparser.AliasedExpr)
+		if !ok {
+			return nil, util.NewParseError(util.CodeInvalidArgument, sqlparser.String(arg), "unsupported argument %s to %s", sqlparser.String(arg), strings.ToUpper(name))
+		}
+		interval, isInterval := aliasedArg.Expr.(*sqlparser.IntervalExpr)
+		valExpr := aliasedArg.Expr
+		if isInterval {
+			valExpr = interval.Expr
+		}
+		val, err := sel.getValueFromExpr(valExpr)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, val)
+		if isInterval {
+			args = append(args, strings.ToUpper(interval.Unit))
+		}
+	}
+
+	switch name {
+	case "timestamp", "date":
+		if len(args) != 1 {
+			return nil, util.NewParseError(util.CodeInvalidArgument, sqlparser.String(funcExpr), "%s expects 1 argument", strings.ToUpper(name))
+		}
+		value, ok := args[0].(string)
+		if !ok {
+			return nil, util.NewParseError(util.CodeInvalidArgument, sqlparser.String(funcExpr), "%s expects a string, got %v", strings.ToUpper(name), args[0])
+		}
+		parse := support.ParseTimestamp
+		if name == "date" {
+			parse = support.ParseDate
+		}
+		timestamp, err := parse(value)
+		if err != nil {
+			return nil, util.NewParseError(util.CodeInvalidArgument, sqlparser.String(funcExpr), "%v", err)
+		}
+		return timestamp, nil
+	case "geopoint":
+		if len(args) != 2 {
+			return nil, util.NewParseError(util.CodeInvalidArgument, sqlparser.String(funcExpr), "GEOPOINT expects 2 arguments: latitude and longitude")
+		}
+		lat, latOk := toFloat(args[0])
+		lng, lngOk := toFloat(args[1])
+		if !latOk || !lngOk {
+			return nil, util.NewParseError(util.CodeInvalidArgument, sqlparser.String(funcExpr), "GEOPOINT expects numeric latitude and longitude, got %v, %v", args[0], args[1])
+		}
+		if lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+			return nil, util.NewParseError(util.CodeInvalidArgument, sqlparser.String(funcExpr), "GEOPOINT(%v, %v) is out of range. latitude must be within [-90, 90] and longitude within [-180, 180]", lat, lng)
+		}
+		return &latlng.LatLng{Latitude: lat, Longitude: lng}, nil
+	case "ref":
+		if len(args) != 1 {
+			return nil, util.NewParseError(util.CodeInvalidArgument, sqlparser.String(funcExpr), "REF expects 1 argument: document path")
+		}
+		path, ok := args[0].(string)
+		if !ok {
+			return nil, util.NewParseError(util.CodeInvalidArgument, sqlparser.String(funcExpr), "REF expects a document path, got %v", args[0])
+		}
+		ref := sel.fireClient.Doc(strings.Trim(path, "/"))
+		if ref == nil {
+			return nil, util.NewParseError(util.CodeInvalidArgument, sqlparser.String(funcExpr), `invalid document path "%s", expected an even number of path segments`, path)
+		}
+		return ref, nil
+	}
+	if !sel.context.Functions.Exists(name) {
+		return nil, util.NewUnsupportedError(util.CodeUnsupportedFunction, sqlparser.String(funcExpr), `unsupported function "%s" in value`, strings.ToUpper(name))
+	}
+	val, err := sel.context.Functions.Exec(name, args)
+	if err != nil {
+		return nil, util.NewParseError(util.CodeInvalidArgument, sqlparser.String(funcExpr), "%v", err)
+	}
+	return val, nil
+}
+
+// getIntervalValue evaluates timestamp arithmetic with intervals, e.g. NOW() - INTERVAL 7 DAY.
+func (sel *SelectStatement) getIntervalValue(binExpr *sqlparser.BinaryExpr) (interface{}, error) {
+	interval, ok := binExpr.Right.(*sqlparser.IntervalExpr)
+	if !ok || (binExpr.Operator != sqlparser.PlusStr && binExpr.Operator != sqlparser.MinusStr) {
+		return nil, util.NewUnsupportedError(util.CodeUnsupportedExpression, sqlparser.String(binExpr), "unsupported value: %s", sqlparser.String(binExpr))
+	}
+	left, err := sel.getValueFromExpr(binExpr.Left)
+	if err != nil {
+		return nil, err
+	}
+	timestamp, ok := left.(time.Time)
+	if !ok {
+		return nil, util.NewParseError(util.CodeInvalidArgument, sqlparser.String(binExpr), "INTERVAL can only be added to or subtracted from timestamps: %s", sqlparser.String(binExpr))
+	}
+	amount, err := sel.getValueFromExpr(interval.Expr)
+	if err != nil {
+		return nil, err
+	}
+	if str, ok := amount.(string); ok {
+		amount, err = strconv.Atoi(str)
+		if err != nil {
+			return nil, util.NewParseError(util.CodeInvalidArgument, sqlparser.String(binExpr), "invalid INTERVAL amount %s", str)
+		}
+	}
+	n, ok := amount.(int)
+	if !ok {
+		return nil, util.NewParseError(util.CodeInvalidArgument, sqlparser.String(binExpr), "INTERVAL amount must be an integer: %s", sqlparser.String(interval))
+	}
+	if binExpr.Operator == sqlparser.MinusStr {
+		n = -n
+	}
+	return support.AddInterval(timestamp, n, interval.Unit)
+}
+
+func toFloat(val interface{}) (float64, bool) {
+	switch val := val.(type) {
+	case int:
+		return float64(val), true
+	case float64:
+		return val, true
+	}
+	return 0, false
+}
@@ -0,0 +1,72 @@
+package _select
+
+import (
+	"cloud.google.com/go/firestore"
+	"github.com/pgollangi/fireql/pkg/util"
+	"github.com/xwb1989/sqlparser"
+	"strings"
+	"testing"
+)
+
+func TestValidateFilter(t *testing.T) {
+	manyValues := make([]interface{}, maxDisjunctionValues+1)
+	tests := []struct {
+		prevOps []string
+		op      string
+		val     interface{}
+		err     string
+	}{
+		{op: "array-contains", val: "x"},
+		{op: "array-contains-any", val: []interface{}{"x", "y"}},
+		{op: "array-contains-any", val: []interface{}{}, err: "accepts 1 to 30 values"},
+		{op: "in", val: manyValues, err: "accepts 1 to 30 values"},
+		{op: "not-in", val: manyValues[:maxNotInValues+1], err: "accepts 1 to 10 values"},
+		{prevOps: []string{"array-contains"}, op: "array-contains-any", val: []interface{}{"x"}, err: "at most one array-contains"},
+		{prevOps: []string{"in"}, op: "not-in", val: []interface{}{"x"}, err: `can't be combined with "in"`},
+		{prevOps: []string{"not-in"}, op: "!=", val: "x", err: `can't be combined with "!="`},
+		{prevOps: []string{"in"}, op: "array-contains", val: "x"},
+	}
+	for _, tt := range tests {
+		sel := &SelectStatement{}
+		for _, prevOp := range tt.prevOps {
+			sel.filters = append(sel.filters, queryFilter{path: []string{"tags"}, op: prevOp})
+		}
+		err := sel.validateFilter("tags", tt.op, tt.val)
+		if tt.err == "" && err != nil {
+			t.Errorf("validateFilter(%v, %s): unexpected error %v", tt.prevOps, tt.op, err)
+		} else if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("validateFilter(%v, %s): expected error %q, actual %v", tt.prevOps, tt.op, tt.err, err)
+		}
+	}
+}
+
+func TestMatchClientFilters(t *testing.T) {
+	document := &firestore.DocumentSnapshot{Ref: &firestore.DocumentRef{ID: "1"}}
+	data := map[string]interface{}{"name": "Terry", "age": int64(30)}
+	tests := []struct {
+		condition string
+		matched   bool
+		err       bool
+	}{
+		{condition: "age > 18", matched: true},
+		{condition: "nick = 'x'"},
+		{condition: "concat(name)"},
+		{condition: "SAFE_CAST(name AS INT64) > 1"},
+		{condition: "CAST(name AS INT64) > 1", err: true},
+		{condition: "name regexp '^T' and age + name > 1", err: true},
+	}
+	for _, tt := range tests {
+		stmt, err := sqlparser.Parse(rewriteQuery("select * from users where " + tt.condition))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sel := &SelectStatement{context: &util.Context{}}
+		if err := sel.addClientFilter(stmt.(*sqlparser.Select).Where.Expr); err != nil {
+			t.Fatalf("%s: %v", tt.condition, err)
+		}
+		matched, err := sel.matchClientFilters(document, &data, &evalEnv{})
+		if matched != tt.matched || (err != nil) != tt.err {
+			t.Errorf("%s: expected %v with error %v, actual %v, %v", tt.condition, tt.matched, tt.err, matched, err)
+		}
+	}
+}
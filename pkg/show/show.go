@@ -0,0 +1,85 @@
+package show
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"errors"
+	"github.com/pgollangi/fireql/pkg/util"
+	"google.golang.org/api/iterator"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// showCollectionsRegex matches SHOW COLLECTIONS with an optional
+// FROM clause holding a quoted document path.
+var showCollectionsRegex = regexp.MustCompile("(?is)^\\s*show\\s+collections(\\s+from\\s+(?:'([^']*)'|\"([^\"]*)\"|`([^`]*)`))?\\s*;?\\s*$")
+
+type ShowStatement struct {
+	context  *util.Context
+	rawQuery string
+}
+
+func New(context *util.Context, rawQuery string) *ShowStatement {
+	return &ShowStatement{
+		context,
+		rawQuery,
+	}
+}
+
+func (show *ShowStatement) Execute() (*util.QueryResult, error) {
+	matches := showCollectionsRegex.FindStringSubmatch(show.rawQuery)
+	if matches == nil {
+		return nil, util.NewUnsupportedError(util.CodeUnsupportedStatement, show.rawQuery, "unsupported SHOW statement. supported: SHOW COLLECTIONS [FROM 'document/path']")
+	}
+	rawPath := matches[2] + matches[3] + matches[4]
+	docPath := strings.Trim(rawPath, "/")
+	// FROM '' or a path with empty segments such as 'users//abc/x' names no document
+	if matches[1] != "" && (docPath == "" || strings.Contains(docPath, "//")) {
+		return nil, util.NewParseError(util.CodeInvalidArgument, rawPath, `invalid document path "%s", expected non-empty path segments`, rawPath)
+	}
+
+	fireClient, err := util.NewFireClient(show.context)
+	if err != nil {
+		return nil, err
+	}
+	defer fireClient.Close()
+
+	var collections *firestore.CollectionIterator
+	if docPath == "" {
+		collections = fireClient.Collections(context.Background())
+	} else {
+		doc := fireClient.Doc(docPath)
+		if doc == nil {
+			return nil, util.NewParseError(util.CodeInvalidArgument, docPath, `invalid document path "%s", expected an even number of path segments`, docPath)
+		}
+		collections = doc.Collections(context.Background())
+	}
+
+	var records [][]interface{}
+	for {
+		collection, err := collections.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		} else if err != nil {
+			return nil, util.NewFirestoreError(err)
+		}
+		records = append(records, []interface{}{collection.ID, relativePath(collection)})
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i][0].(string) < records[j][0].(string)
+	})
+	if records == nil {
+		records = [][]interface{}{}
+	}
+	return &util.QueryResult{Columns: []string{"collection", "path"}, Records: records}, nil
+}
+
+// relativePath returns path of the collection relative to the database root,
+// e.g. "users/abc/orders".
+func relativePath(collection *firestore.CollectionRef) string {
+	if collection.Parent == nil {
+		return collection.ID
+	}
+	return relativePath(collection.Parent.Parent) + "/" + collection.Parent.ID + "/" + collection.ID
+}
@@ -0,0 +1,129 @@
+package show
+
+import (
+	"context"
+	"errors"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pgollangi/fireql/pkg/util"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+
+	"cloud.google.com/go/firestore"
+)
+
+const FirestoreEmulatorHost = "FIRESTORE_EMULATOR_HOST"
+
+func TestMain(m *testing.M) {
+	// command to start firestore emulator, on a port of its own as packages are tested in parallel
+	cmd := exec.Command("gcloud", "beta", "emulators", "firestore", "start", "--host-port=localhost:8766")
+
+	// this makes it killable
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	// we need to capture it's output to know when it's started
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer stderr.Close()
+
+	if err := cmd.Start(); err != nil {
+		log.Fatal(err)
+	}
+
+	var result int
+	defer func() {
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		os.Exit(result)
+	}()
+
+	// wait until the emulator reports it's running
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		buf := make([]byte, 256)
+		for {
+			n, err := stderr.Read(buf)
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				log.Fatalf("reading stderr %v", err)
+			}
+			if n > 0 && strings.Contains(string(buf[:n]), "Dev App Server is now running") {
+				wg.Done()
+			}
+		}
+	}()
+	wg.Wait()
+
+	os.Setenv(FirestoreEmulatorHost, "localhost:8766")
+	ctx := context.Background()
+	client, err := firestore.NewClient(ctx, "test")
+	if err != nil {
+		log.Fatalf("firebase.NewClient err: %v", err)
+	}
+	for path, data := range map[string]map[string]interface{}{
+		"users/1":          {"name": "Terry"},
+		"users/1/orders/1": {"item": "Book"},
+		"stores/1":         {"name": "Downtown"},
+	} {
+		if _, err := client.Doc(path).Set(ctx, data); err != nil {
+			log.Fatal(err)
+		}
+	}
+	client.Close()
+
+	result = m.Run()
+}
+
+func TestShowCollections(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected [][]interface{}
+	}{
+		{query: "SHOW COLLECTIONS", expected: [][]interface{}{{"stores", "stores"}, {"users", "users"}}},
+		{query: "show collections from 'users/1';", expected: [][]interface{}{{"orders", "users/1/orders"}}},
+		{query: "show collections from `/users/1/orders/1/`", expected: [][]interface{}{}},
+	}
+	for _, tt := range tests {
+		result, err := New(&util.Context{ProjectId: "test"}, tt.query).Execute()
+		if err != nil {
+			t.Errorf("%s: %v", tt.query, err)
+			continue
+		}
+		if diff := cmp.Diff([]string{"collection", "path"}, result.Columns); diff != "" {
+			t.Errorf("%s: columns mismatch (-expected +actual):\n%s", tt.query, diff)
+		}
+		if diff := cmp.Diff(tt.expected, result.Records); diff != "" {
+			t.Errorf("%s: records mismatch (-expected +actual):\n%s", tt.query, diff)
+		}
+	}
+}
+
+func TestShowCollectionsErrors(t *testing.T) {
+	for _, query := range []string{
+		"show collections from ''",
+		"show collections from '/'",
+		"show collections from 'users//abc/x'",
+		"show collections from 'users'",
+	} {
+		_, err := New(&util.Context{ProjectId: "test"}, query).Execute()
+		var parseErr *util.ParseError
+		if !errors.As(err, &parseErr) || parseErr.Code != util.CodeInvalidArgument {
+			t.Errorf("%s: expected invalid argument error, actual %v", query, err)
+		}
+	}
+
+	_, err := New(&util.Context{ProjectId: "test"}, "show tables").Execute()
+	var unsupportedErr *util.UnsupportedError
+	if !errors.As(err, &unsupportedErr) || unsupportedErr.Code != util.CodeUnsupportedStatement {
+		t.Errorf("show tables: expected unsupported statement error, actual %v", err)
+	}
+}
@@ -0,0 +1,182 @@
+package support
+
+import (
+	"cloud.google.com/go/firestore"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	mustRegister("CAST", Cast, 2, 2, AnyType, "string")
+	mustRegister("SAFE_CAST", SafeCast, 2, 2, AnyType, "string")
+	mustRegister("TYPEOF", TypeOf, 1, 1)
+}
+
+// Cast converts the value to the type of the second param, one of INT, FLOAT, STRING, BOOL
+// and TIMESTAMP, or their aliases, e.g. INT64 or BOOLEAN. NULL is NULL of any type.
+func Cast(data []interface{}) (interface{}, error) {
+	return castValue("CAST", data)
+}
+
+// SafeCast converts the value like Cast, but returns NULL when it can't be converted.
+func SafeCast(data []interface{}) (interface{}, error) {
+	if _, err := castType("SAFE_CAST", data[1]); err != nil {
+		return nil, err
+	}
+	val, err := castValue("SAFE_CAST", data)
+	if err != nil {
+		return nil, nil
+	}
+	return val, nil
+}
+
+// TypeOf returns the Firestore type of the value, e.g. "integer", "string" or "null",
+// named as DESCRIBE names field types.
+func TypeOf(data []interface{}) (interface{}, error) {
+	return FirestoreType(data[0]), nil
+}
+
+// castType returns the Firestore type of the type name.
+func castType(name string, typeName interface{}) (string, error) {
+	typ, _ := typeName.(string)
+	switch strings.ToUpper(typ) {
+	case "INT", "INT64", "INTEGER", "BIGINT":
+		return "integer", nil
+	case "FLOAT", "FLOAT64", "DOUBLE", "NUMERIC", "DECIMAL":
+		return "double", nil
+	case "STRING", "TEXT", "VARCHAR", "CHAR":
+		return "string", nil
+	case "BOOL", "BOOLEAN":
+		return "boolean", nil
+	case "TIMESTAMP", "DATETIME":
+		return "timestamp", nil
+	}
+	return "", fmt.Errorf(`unsupported type %v of "%s" function, expected INT, FLOAT, STRING, BOOL or TIMESTAMP`, typeName, name)
+}
+
+func castValue(name string, data []interface{}) (interface{}, error) {
+	typ, err := castType(name, data[1])
+	if err != nil {
+		return nil, err
+	}
+	val := data[0]
+	if val == nil {
+		return nil, nil
+	}
+	var result interface{}
+	switch typ {
+	case "integer":
+		result = castInt(val)
+	case "double":
+		result = castFloat(val)
+	case "string":
+		result = castString(val)
+	case "boolean":
+		result = castBool(val)
+	case "timestamp":
+		result = castTimestamp(val)
+	}
+	if result == nil {
+		return nil, fmt.Errorf(`couldn't cast %s %v to %s`, FirestoreType(val), val, strings.ToUpper(data[1].(string)))
+	}
+	return result, nil
+}
+
+func castInt(val interface{}) interface{} {
+	switch val := val.(type) {
+	case int:
+		return int64(val)
+	case int64:
+		return val
+	case float64:
+		// rounds halfway values away from zero
+		if rounded := math.Round(val); !math.IsNaN(val) && rounded >= math.MinInt64 && rounded < math.MaxInt64 {
+			return int64(rounded)
+		}
+	case string:
+		if i, err := strconv.ParseInt(strings.TrimSpace(val), 10, 64); err == nil {
+			return i
+		}
+	case bool:
+		if val {
+			return int64(1)
+		}
+		return int64(0)
+	}
+	return nil
+}
+
+func castFloat(val interface{}) interface{} {
+	switch val := val.(type) {
+	case int:
+		return float64(val)
+	case int64:
+		return float64(val)
+	case float64:
+		return val
+	case string:
+		if f, err := strconv.ParseFloat(strings.TrimSpace(val), 64); err == nil {
+			return f
+		}
+	}
+	return nil
+}
+
+func castString(val interface{}) interface{} {
+	switch val := val.(type) {
+	case string:
+		return val
+	case int:
+		return strconv.Itoa(val)
+	case int64:
+		return strconv.FormatInt(val, 10)
+	case float64:
+		return strconv.FormatFloat(val, 'g', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
+	case []byte:
+		return string(val)
+	case time.Time:
+		return val.Format(time.RFC3339Nano)
+	case *firestore.DocumentRef:
+		// relative to the database root, e.g. users/abc
+		if idx := strings.Index(val.Path, "/documents/"); idx >= 0 {
+			return val.Path[idx+len("/documents/"):]
+		}
+		return val.Path
+	}
+	return nil
+}
+
+func castBool(val interface{}) interface{} {
+	switch val := val.(type) {
+	case bool:
+		return val
+	case int:
+		return val != 0
+	case int64:
+		return val != 0
+	case float64:
+		return val != 0
+	case string:
+		if b, err := strconv.ParseBool(strings.TrimSpace(val)); err == nil {
+			return b
+		}
+	}
+	return nil
+}
+
+func castTimestamp(val interface{}) interface{} {
+	switch val := val.(type) {
+	case time.Time:
+		return val
+	case string:
+		if t, err := ParseTimestamp(val); err == nil {
+			return t
+		}
+	}
+	return nil
+}
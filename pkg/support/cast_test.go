@@ -0,0 +1,63 @@
+package support
+
+import (
+	"google.golang.org/genproto/googleapis/type/latlng"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCastFunctions(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name     string
+		params   []interface{}
+		expected interface{}
+	}{
+		{"CAST", []interface{}{" 42 ", "INT"}, int64(42)},
+		{"CAST", []interface{}{float64(2.5), "int64"}, int64(3)},
+		{"CAST", []interface{}{true, "INT"}, int64(1)},
+		{"CAST", []interface{}{"1.5e3", "FLOAT"}, float64(1500)},
+		{"CAST", []interface{}{int64(7), "DOUBLE"}, float64(7)},
+		{"CAST", []interface{}{float64(0.1), "STRING"}, "0.1"},
+		{"CAST", []interface{}{ts, "STRING"}, "2024-01-02T03:04:05Z"},
+		{"CAST", []interface{}{"TRUE", "BOOL"}, true},
+		{"CAST", []interface{}{int64(0), "BOOLEAN"}, false},
+		{"CAST", []interface{}{"2024-01-02 03:04:05", "TIMESTAMP"}, ts},
+		{"CAST", []interface{}{nil, "INT"}, nil},
+		{"SAFE_CAST", []interface{}{"abc", "INT"}, nil},
+		{"SAFE_CAST", []interface{}{"12", "INT"}, int64(12)},
+		{"TYPEOF", []interface{}{int64(1)}, "integer"},
+		{"TYPEOF", []interface{}{"1"}, "string"},
+		{"TYPEOF", []interface{}{nil}, "null"},
+		{"TYPEOF", []interface{}{map[string]interface{}{}}, "map"},
+		{"TYPEOF", []interface{}{&ts}, "timestamp"},
+		{"TYPEOF", []interface{}{latlng.LatLng{Latitude: 1}}, "geopoint"},
+		{"TYPEOF", []interface{}{&latlng.LatLng{Latitude: 1}}, "geopoint"},
+		{"TYPEOF", []interface{}{struct{}{}}, "unknown"},
+	}
+	for _, tt := range tests {
+		actual, err := ExecFunc(tt.name, tt.params)
+		if err != nil {
+			t.Errorf("%s%v: %v", tt.name, tt.params, err)
+		} else if !reflect.DeepEqual(actual, tt.expected) {
+			t.Errorf("%s%v: expected %#v, actual %#v", tt.name, tt.params, tt.expected, actual)
+		}
+	}
+
+	for _, call := range []struct {
+		name   string
+		params []interface{}
+	}{
+		{"CAST", []interface{}{"abc", "INT"}},
+		{"CAST", []interface{}{"1.5", "INT"}},
+		{"CAST", []interface{}{[]interface{}{}, "STRING"}},
+		{"CAST", []interface{}{ts, "FLOAT"}},
+		{"CAST", []interface{}{"1", "DECIMAL(10)"}},
+		{"SAFE_CAST", []interface{}{"1", "JSON"}},
+	} {
+		if _, err := ExecFunc(call.name, call.params); err == nil {
+			t.Errorf("%s%v: expected error", call.name, call.params)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package support
+
+import (
+	"bytes"
+	"cloud.google.com/go/firestore"
+	"fmt"
+	"google.golang.org/genproto/googleapis/type/latlng"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	mustRegister("ARRAY_CONTAINS", nullable(ArrayContains), 2, 2, "array", AnyType)
+	mustRegister("ARRAY_FIRST", nullable(ArrayFirst), 1, 1, "array")
+	mustRegister("ARRAY_SLICE", nullable(ArraySlice), 2, 3, "array", NumberType)
+	mustRegister("ARRAY_JOIN", ArrayJoin, 2, 3, "array", "string")
+	mustRegister("ARRAY_DISTINCT", nullable(ArrayDistinct), 1, 1, "array")
+	mustRegister("MAP_KEYS", nullable(MapKeys), 1, 1, "map")
+	mustRegister("MAP_VALUES", nullable(MapValues), 1, 1, "map")
+	mustRegister("GET", Get, 2, 2)
+}
+
+// ArrayContains reports whether the array has an element equal to the value.
+// Integers and doubles of the same value are equal.
+func ArrayContains(data []interface{}) (interface{}, error) {
+	for _, element := range data[0].([]interface{}) {
+		if valuesEqual(element, data[1]) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ArrayFirst returns the first element of the array, or NULL when it's empty.
+func ArrayFirst(data []interface{}) (interface{}, error) {
+	array := data[0].([]interface{})
+	if len(array) == 0 {
+		return nil, nil
+	}
+	return array[0], nil
+}
+
+// ArraySlice returns elements from the 0-based start index up to, excluding, the end index,
+// or up to the end of the array. Negative indexes count from the end of the array.
+func ArraySlice(data []interface{}) (interface{}, error) {
+	array := data[0].([]interface{})
+	start, err := intParam("ARRAY_SLICE", data, 1)
+	if err != nil {
+		return nil, err
+	}
+	end := len(array)
+	if len(data) == 3 {
+		if end, err = intParam("ARRAY_SLICE", data, 2); err != nil {
+			return nil, err
+		}
+	}
+	start, end = sliceIndex(start, len(array)), sliceIndex(end, len(array))
+	if start >= end {
+		return []interface{}{}, nil
+	}
+	return append([]interface{}{}, array[start:end]...), nil
+}
+
+func sliceIndex(idx int, length int) int {
+	if idx < 0 {
+		idx += length
+	}
+	if idx < 0 {
+		return 0
+	}
+	if idx > length {
+		return length
+	}
+	return idx
+}
+
+// ArrayJoin concatenates elements of the array separated by the delimiter. NULL elements
+// are skipped, or replaced by the third param if given.
+func ArrayJoin(data []interface{}) (interface{}, error) {
+	if data[0] == nil || data[1] == nil {
+		return nil, nil
+	}
+	var parts []string
+	for idx, element := range data[0].([]interface{}) {
+		if element == nil {
+			if len(data) == 3 && data[2] != nil {
+				parts = append(parts, data[2].(string))
+			}
+			continue
+		}
+		part, err := Concat([]interface{}{element})
+		if err != nil {
+			return nil, fmt.Errorf(`element %d of "ARRAY_JOIN" array must be string, got %s`, idx, FirestoreType(element))
+		}
+		parts = append(parts, part.(string))
+	}
+	return strings.Join(parts, data[1].(string)), nil
+}
+
+// ArrayDistinct returns elements of the array without duplicates, in the order of their first occurrence.
+func ArrayDistinct(data []interface{}) (interface{}, error) {
+	result := []interface{}{}
+	for _, element := range data[0].([]interface{}) {
+		duplicate := false
+		for _, distinct := range result {
+			if valuesEqual(element, distinct) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			result = append(result, element)
+		}
+	}
+	return result, nil
+}
+
+// MapKeys returns keys of the map in ascending order.
+func MapKeys(data []interface{}) (interface{}, error) {
+	keys := mapKeys(data[0].(map[string]interface{}))
+	result := make([]interface{}, len(keys))
+	for idx, key := range keys {
+		result[idx] = key
+	}
+	return result, nil
+}
+
+// MapValues returns values of the map in ascending order of their keys.
+func MapValues(data []interface{}) (interface{}, error) {
+	m := data[0].(map[string]interface{})
+	keys := mapKeys(m)
+	result := make([]interface{}, len(keys))
+	for idx, key := range keys {
+		result[idx] = m[key]
+	}
+	return result, nil
+}
+
+func mapKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Get returns the element of an array at the 0-based index, or the value of a map at the path
+// of keys separated by dots, e.g. 'address.city', where integer keys index arrays.
+// Returns NULL when there is no such element.
+func Get(data []interface{}) (interface{}, error) {
+	val := data[0]
+	switch key := data[1].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		for _, segment := range strings.Split(key, ".") {
+			if val = element(val, segment); val == nil {
+				return nil, nil
+			}
+		}
+		return val, nil
+	}
+	if _, isArray := val.([]interface{}); val != nil && !isArray {
+		return nil, fmt.Errorf(`param 1 of "GET" function must be array when indexed by number, got %s`, FirestoreType(val))
+	}
+	idx, err := intParam("GET", data, 1)
+	if err != nil {
+		return nil, err
+	}
+	return element(val, strconv.Itoa(idx)), nil
+}
+
+// element returns the element of the array or map by the key, or nil if there is no such element.
+func element(container interface{}, key string) interface{} {
+	switch container := container.(type) {
+	case map[string]interface{}:
+		return container[key]
+	case []interface{}:
+		idx, err := strconv.Atoi(key)
+		if err != nil || idx < 0 || idx >= len(container) {
+			return nil
+		}
+		return container[idx]
+	}
+	return nil
+}
+
+// valuesEqual reports whether the values are equal Firestore values.
+func valuesEqual(left interface{}, right interface{}) bool {
+	if leftNum, ok := numberValue(left); ok {
+		rightNum, ok := numberValue(right)
+		return ok && leftNum == rightNum
+	}
+	switch left := left.(type) {
+	case time.Time:
+		right, ok := right.(time.Time)
+		return ok && left.Equal(right)
+	case []byte:
+		right, ok := right.([]byte)
+		return ok && bytes.Equal(left, right)
+	case *firestore.DocumentRef:
+		right, ok := right.(*firestore.DocumentRef)
+		return ok && left.Path == right.Path
+	case *latlng.LatLng:
+		right, ok := right.(*latlng.LatLng)
+		return ok && left.GetLatitude() == right.GetLatitude() && left.GetLongitude() == right.GetLongitude()
+	case []interface{}:
+		right, ok := right.([]interface{})
+		if !ok || len(left) != len(right) {
+			return false
+		}
+		for idx := range left {
+			if !valuesEqual(left[idx], right[idx]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		right, ok := right.(map[string]interface{})
+		if !ok || len(left) != len(right) {
+			return false
+		}
+		for key, val := range left {
+			if rightVal, ok := right[key]; !ok || !valuesEqual(val, rightVal) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(left, right)
+}
+
+func numberValue(val interface{}) (float64, bool) {
+	switch val := val.(type) {
+	case int:
+		return float64(val), true
+	case int64:
+		return float64(val), true
+	case float64:
+		return val, true
+	}
+	return 0, false
+}
@@ -0,0 +1,65 @@
+package support
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCollectionFunctions(t *testing.T) {
+	tags := []interface{}{"go", "sql", "go", nil, int64(3)}
+	address := map[string]interface{}{
+		"city":  "Paris",
+		"zip":   int64(75001),
+		"lines": []interface{}{"1 Rue", map[string]interface{}{"floor": int64(2)}},
+	}
+	tests := []struct {
+		name     string
+		params   []interface{}
+		expected interface{}
+	}{
+		{"ARRAY_CONTAINS", []interface{}{tags, "sql"}, true},
+		{"ARRAY_CONTAINS", []interface{}{tags, float64(3)}, true},
+		{"ARRAY_CONTAINS", []interface{}{tags, "java"}, false},
+		{"ARRAY_CONTAINS", []interface{}{nil, "go"}, nil},
+		{"ARRAY_FIRST", []interface{}{tags}, "go"},
+		{"ARRAY_FIRST", []interface{}{[]interface{}{}}, nil},
+		{"ARRAY_SLICE", []interface{}{tags, float64(1), float64(3)}, []interface{}{"sql", "go"}},
+		{"ARRAY_SLICE", []interface{}{tags, float64(-2)}, []interface{}{nil, int64(3)}},
+		{"ARRAY_SLICE", []interface{}{tags, float64(4), float64(2)}, []interface{}{}},
+		{"ARRAY_JOIN", []interface{}{tags, ", "}, "go, sql, go, 3"},
+		{"ARRAY_JOIN", []interface{}{tags, "|", "-"}, "go|sql|go|-|3"},
+		{"ARRAY_DISTINCT", []interface{}{tags}, []interface{}{"go", "sql", nil, int64(3)}},
+		{"MAP_KEYS", []interface{}{address}, []interface{}{"city", "lines", "zip"}},
+		{"MAP_VALUES", []interface{}{map[string]interface{}{"b": int64(2), "a": "x"}}, []interface{}{"x", int64(2)}},
+		{"GET", []interface{}{address, "city"}, "Paris"},
+		{"GET", []interface{}{address, "lines.1.floor"}, int64(2)},
+		{"GET", []interface{}{address, "country.code"}, nil},
+		{"GET", []interface{}{tags, float64(1)}, "sql"},
+		{"GET", []interface{}{tags, int64(10)}, nil},
+		{"GET", []interface{}{tags, float64(-1)}, nil},
+		{"GET", []interface{}{nil, float64(0)}, nil},
+	}
+	for _, tt := range tests {
+		actual, err := ExecFunc(tt.name, tt.params)
+		if err != nil {
+			t.Errorf("%s%v: %v", tt.name, tt.params, err)
+		} else if !reflect.DeepEqual(actual, tt.expected) {
+			t.Errorf("%s%v: expected %#v, actual %#v", tt.name, tt.params, tt.expected, actual)
+		}
+	}
+
+	for _, call := range []struct {
+		name   string
+		params []interface{}
+	}{
+		{"ARRAY_FIRST", []interface{}{"go"}},
+		{"ARRAY_SLICE", []interface{}{tags, float64(0.5)}},
+		{"ARRAY_JOIN", []interface{}{[]interface{}{[]interface{}{}}, ","}},
+		{"MAP_KEYS", []interface{}{tags}},
+		{"GET", []interface{}{address, float64(0)}},
+	} {
+		if _, err := ExecFunc(call.name, call.params); err == nil {
+			t.Errorf("%s%v: expected error", call.name, call.params)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package support
+
+func init() {
+	mustRegister("COALESCE", Coalesce, 1, VariadicParams)
+	mustRegister("IFNULL", Coalesce, 2, 2)
+	mustRegister("NULLIF", NullIf, 2, 2)
+	mustRegister("IF", If, 3, 3)
+}
+
+// Coalesce returns the first param that isn't NULL, or NULL if all are NULL.
+func Coalesce(data []interface{}) (interface{}, error) {
+	for _, param := range data {
+		if param != nil {
+			return param, nil
+		}
+	}
+	return nil, nil
+}
+
+// NullIf returns NULL if both params are equal, or the first param otherwise.
+func NullIf(data []interface{}) (interface{}, error) {
+	if data[0] != nil && valuesEqual(data[0], data[1]) {
+		return nil, nil
+	}
+	return data[0], nil
+}
+
+// If returns the second param if the first is true, or the third param otherwise,
+// including when the first is NULL.
+func If(data []interface{}) (interface{}, error) {
+	if condition, ok := data[0].(bool); ok && condition {
+		return data[1], nil
+	}
+	return data[2], nil
+}
@@ -0,0 +1,32 @@
+package support
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConditionalFunctions(t *testing.T) {
+	tests := []struct {
+		name     string
+		params   []interface{}
+		expected interface{}
+	}{
+		{"COALESCE", []interface{}{nil, nil, "a", "b"}, "a"},
+		{"COALESCE", []interface{}{nil}, nil},
+		{"IFNULL", []interface{}{nil, float64(0)}, float64(0)},
+		{"IFNULL", []interface{}{false, true}, false},
+		{"NULLIF", []interface{}{int64(1), float64(1)}, nil},
+		{"NULLIF", []interface{}{"a", "b"}, "a"},
+		{"NULLIF", []interface{}{nil, nil}, nil},
+		{"IF", []interface{}{true, "yes", "no"}, "yes"},
+		{"IF", []interface{}{nil, "yes", "no"}, "no"},
+	}
+	for _, tt := range tests {
+		actual, err := ExecFunc(tt.name, tt.params)
+		if err != nil {
+			t.Errorf("%s%v: %v", tt.name, tt.params, err)
+		} else if !reflect.DeepEqual(actual, tt.expected) {
+			t.Errorf("%s%v: expected %#v, actual %#v", tt.name, tt.params, tt.expected, actual)
+		}
+	}
+}
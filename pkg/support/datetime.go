@@ -0,0 +1,329 @@
+package support
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	// Time zones are needed in images without zoneinfo, e.g. the Docker image
+	_ "time/tzdata"
+)
+
+func init() {
+	mustRegister("DATE_TRUNC", nullable(DateTrunc), 2, 3, "timestamp", "string")
+	mustRegister("TIMESTAMP_TRUNC", nullable(DateTrunc), 2, 3, "timestamp", "string")
+	mustRegister("EXTRACT", nullable(Extract), 2, 3, "string", "timestamp", "string")
+	mustRegister("FORMAT_TIMESTAMP", nullable(FormatTimestamp), 2, 3, "string", "timestamp", "string")
+	mustRegister("TIMESTAMP_ADD", nullable(TimestampAdd), 3, 3, "timestamp", NumberType, "string")
+	mustRegister("TIMESTAMP_SUB", nullable(TimestampSub), 3, 3, "timestamp", NumberType, "string")
+	mustRegister("TIMESTAMP_DIFF", nullable(TimestampDiff), 3, 3, "timestamp", "timestamp", "string")
+	mustRegister("UNIX_SECONDS", nullable(unixTime(time.Second)), 1, 1, "timestamp")
+	mustRegister("UNIX_MILLIS", nullable(unixTime(time.Millisecond)), 1, 1, "timestamp")
+	mustRegister("UNIX_MICROS", nullable(unixTime(time.Microsecond)), 1, 1, "timestamp")
+	mustRegister("TIMESTAMP_SECONDS", nullable(timestampFromUnix("TIMESTAMP_SECONDS", time.Second)), 1, 1, NumberType)
+	mustRegister("TIMESTAMP_MILLIS", nullable(timestampFromUnix("TIMESTAMP_MILLIS", time.Millisecond)), 1, 1, NumberType)
+	mustRegister("TIMESTAMP_MICROS", nullable(timestampFromUnix("TIMESTAMP_MICROS", time.Microsecond)), 1, 1, NumberType)
+	mustRegister("CONVERT_TZ", nullable(ConvertTz), 2, 3, "timestamp", "string")
+	mustRegisterVolatile("NOW", Now, 0, 0)
+	mustRegisterVolatile("CURRENT_TIMESTAMP", Now, 0, 0)
+}
+
+// DateTrunc truncates the timestamp to the beginning of the date part, e.g. 'DAY' or 'MONTH',
+// in the time zone of the third param or of the timestamp. Weeks start on Sunday, ISO weeks on Monday.
+func DateTrunc(data []interface{}) (interface{}, error) {
+	t, err := inTimeZone("DATE_TRUNC", data[0].(time.Time), data, 2)
+	if err != nil {
+		return nil, err
+	}
+	year, month, day := t.Date()
+	switch part := strings.ToUpper(data[1].(string)); part {
+	case "MICROSECOND":
+		return t.Truncate(time.Microsecond), nil
+	case "MILLISECOND":
+		return t.Truncate(time.Millisecond), nil
+	case "SECOND":
+		return time.Date(year, month, day, t.Hour(), t.Minute(), t.Second(), 0, t.Location()), nil
+	case "MINUTE":
+		return time.Date(year, month, day, t.Hour(), t.Minute(), 0, 0, t.Location()), nil
+	case "HOUR":
+		return time.Date(year, month, day, t.Hour(), 0, 0, 0, t.Location()), nil
+	case "DAY":
+		return time.Date(year, month, day, 0, 0, 0, 0, t.Location()), nil
+	case "WEEK":
+		return time.Date(year, month, day-int(t.Weekday()), 0, 0, 0, 0, t.Location()), nil
+	case "ISOWEEK":
+		return time.Date(year, month, day-(int(t.Weekday())+6)%7, 0, 0, 0, 0, t.Location()), nil
+	case "MONTH":
+		return time.Date(year, month, 1, 0, 0, 0, 0, t.Location()), nil
+	case "QUARTER":
+		return time.Date(year, month-(month-1)%3, 1, 0, 0, 0, 0, t.Location()), nil
+	case "YEAR":
+		return time.Date(year, 1, 1, 0, 0, 0, 0, t.Location()), nil
+	default:
+		return nil, unsupportedDatePart("DATE_TRUNC", part)
+	}
+}
+
+// Extract returns the date part of the timestamp, e.g. 'YEAR' or 'HOUR', in the time zone of
+// the third param or of the timestamp. DAYOFWEEK is 1 for Sunday, WEEK numbers weeks starting on Sunday.
+func Extract(data []interface{}) (interface{}, error) {
+	t, err := inTimeZone("EXTRACT", data[1].(time.Time), data, 2)
+	if err != nil {
+		return nil, err
+	}
+	var val int
+	switch part := strings.ToUpper(data[0].(string)); part {
+	case "MICROSECOND":
+		val = t.Nanosecond() / 1e3
+	case "MILLISECOND":
+		val = t.Nanosecond() / 1e6
+	case "SECOND":
+		val = t.Second()
+	case "MINUTE":
+		val = t.Minute()
+	case "HOUR":
+		val = t.Hour()
+	case "DAY":
+		val = t.Day()
+	case "DAYOFWEEK":
+		val = int(t.Weekday()) + 1
+	case "DAYOFYEAR":
+		val = t.YearDay()
+	case "WEEK":
+		val = (t.YearDay() + 6 - int(t.Weekday())) / 7
+	case "ISOWEEK":
+		_, val = t.ISOWeek()
+	case "MONTH":
+		val = int(t.Month())
+	case "QUARTER":
+		val = (int(t.Month())-1)/3 + 1
+	case "YEAR":
+		val = t.Year()
+	case "ISOYEAR":
+		val, _ = t.ISOWeek()
+	default:
+		return nil, unsupportedDatePart("EXTRACT", part)
+	}
+	return float64(val), nil
+}
+
+// FormatTimestamp formats the timestamp with strftime format elements, e.g. '%Y-%m-%d %H:%M',
+// in the time zone of the third param or of the timestamp.
+func FormatTimestamp(data []interface{}) (interface{}, error) {
+	t, err := inTimeZone("FORMAT_TIMESTAMP", data[1].(time.Time), data, 2)
+	if err != nil {
+		return nil, err
+	}
+	format := data[0].(string)
+	var result strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			result.WriteByte(format[i])
+			continue
+		}
+		i++
+		switch format[i] {
+		case 'Y':
+			result.WriteString(strconv.Itoa(t.Year()))
+		case 'y':
+			result.WriteString(t.Format("06"))
+		case 'm':
+			result.WriteString(t.Format("01"))
+		case 'd':
+			result.WriteString(t.Format("02"))
+		case 'e':
+			result.WriteString(t.Format("_2"))
+		case 'j':
+			result.WriteString(fmt.Sprintf("%03d", t.YearDay()))
+		case 'H':
+			result.WriteString(t.Format("15"))
+		case 'I':
+			result.WriteString(t.Format("03"))
+		case 'M':
+			result.WriteString(t.Format("04"))
+		case 'S':
+			result.WriteString(t.Format("05"))
+		case 'f':
+			result.WriteString(fmt.Sprintf("%06d", t.Nanosecond()/1e3))
+		case 'p':
+			result.WriteString(t.Format("PM"))
+		case 'a':
+			result.WriteString(t.Format("Mon"))
+		case 'A':
+			result.WriteString(t.Format("Monday"))
+		case 'b', 'h':
+			result.WriteString(t.Format("Jan"))
+		case 'B':
+			result.WriteString(t.Format("January"))
+		case 'u':
+			result.WriteString(strconv.Itoa((int(t.Weekday())+6)%7 + 1))
+		case 'w':
+			result.WriteString(strconv.Itoa(int(t.Weekday())))
+		case 'U':
+			result.WriteString(fmt.Sprintf("%02d", (t.YearDay()+6-int(t.Weekday()))/7))
+		case 'V':
+			_, week := t.ISOWeek()
+			result.WriteString(fmt.Sprintf("%02d", week))
+		case 'G':
+			year, _ := t.ISOWeek()
+			result.WriteString(strconv.Itoa(year))
+		case 'Q':
+			result.WriteString(strconv.Itoa((int(t.Month())-1)/3 + 1))
+		case 'F':
+			result.WriteString(t.Format("2006-01-02"))
+		case 'T':
+			result.WriteString(t.Format("15:04:05"))
+		case 'R':
+			result.WriteString(t.Format("15:04"))
+		case 'D':
+			result.WriteString(t.Format("01/02/06"))
+		case 'Z':
+			result.WriteString(t.Format("MST"))
+		case 'z':
+			result.WriteString(t.Format("-0700"))
+		case 's':
+			result.WriteString(strconv.FormatInt(t.Unix(), 10))
+		case '%':
+			result.WriteByte('%')
+		default:
+			return nil, fmt.Errorf(`param 1 of "FORMAT_TIMESTAMP" function has unsupported format element %%%c`, format[i])
+		}
+	}
+	return result.String(), nil
+}
+
+// clock returns the current time read by NOW(), replaced in tests.
+var clock = time.Now
+
+func Now(data []interface{}) (interface{}, error) {
+	return clock().UTC(), nil
+}
+
+// TimestampAdd adds the amount of the date part, e.g. 'DAY', to the timestamp.
+func TimestampAdd(data []interface{}) (interface{}, error) {
+	return addTimestamp("TIMESTAMP_ADD", data, 1)
+}
+
+// TimestampSub subtracts the amount of the date part, e.g. 'DAY', from the timestamp.
+func TimestampSub(data []interface{}) (interface{}, error) {
+	return addTimestamp("TIMESTAMP_SUB", data, -1)
+}
+
+func addTimestamp(name string, data []interface{}, sign int) (interface{}, error) {
+	amount, err := intParam(name, data, 1)
+	if err != nil {
+		return nil, err
+	}
+	t, err := AddInterval(data[0].(time.Time), sign*amount, data[2].(string))
+	if err != nil {
+		return nil, fmt.Errorf(`param 3 of "%s" function: %v`, name, err)
+	}
+	return t, nil
+}
+
+// TimestampDiff returns the number of whole date parts, up to 'DAY' of 24 hours,
+// between the second and the first timestamp.
+func TimestampDiff(data []interface{}) (interface{}, error) {
+	diff := data[0].(time.Time).Sub(data[1].(time.Time))
+	var unit time.Duration
+	switch part := strings.ToUpper(data[2].(string)); part {
+	case "MICROSECOND":
+		unit = time.Microsecond
+	case "MILLISECOND":
+		unit = time.Millisecond
+	case "SECOND":
+		unit = time.Second
+	case "MINUTE":
+		unit = time.Minute
+	case "HOUR":
+		unit = time.Hour
+	case "DAY":
+		unit = 24 * time.Hour
+	default:
+		return nil, unsupportedDatePart("TIMESTAMP_DIFF", part)
+	}
+	return float64(diff / unit), nil
+}
+
+func unixTime(unit time.Duration) Function {
+	return func(data []interface{}) (interface{}, error) {
+		nanos := data[0].(time.Time).UnixNano()
+		val := nanos / int64(unit)
+		if nanos%int64(unit) < 0 {
+			val--
+		}
+		return float64(val), nil
+	}
+}
+
+func timestampFromUnix(name string, unit time.Duration) Function {
+	return func(data []interface{}) (interface{}, error) {
+		var nanos int64
+		switch val := data[0].(type) {
+		case int:
+			nanos = int64(val) * int64(unit)
+		case int64:
+			nanos = val * int64(unit)
+		case float64:
+			if val != math.Trunc(val) || math.Abs(val*float64(unit)) > math.MaxInt64 {
+				return nil, fmt.Errorf(`param 1 of "%s" function must be an integer, got %v`, name, val)
+			}
+			nanos = int64(val) * int64(unit)
+		}
+		return time.Unix(0, nanos).UTC(), nil
+	}
+}
+
+// ConvertTz converts the timestamp to the time zone, e.g. 'America/New_York'. With three params,
+// the wall clock time of the timestamp is considered to be in the time zone of the second param
+// and is converted to the time zone of the third param.
+func ConvertTz(data []interface{}) (interface{}, error) {
+	t := data[0].(time.Time)
+	zone := data[1].(string)
+	if len(data) == 3 {
+		from, err := loadLocation("CONVERT_TZ", 2, zone)
+		if err != nil {
+			return nil, err
+		}
+		t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), from)
+		zone = data[2].(string)
+	}
+	location, err := loadLocation("CONVERT_TZ", len(data), zone)
+	if err != nil {
+		return nil, err
+	}
+	return t.In(location), nil
+}
+
+// inTimeZone returns the timestamp in the time zone of the param at the index, if there is one.
+func inTimeZone(name string, t time.Time, data []interface{}, idx int) (time.Time, error) {
+	if len(data) <= idx {
+		return t, nil
+	}
+	location, err := loadLocation(name, idx+1, data[idx].(string))
+	if err != nil {
+		return t, err
+	}
+	return t.In(location), nil
+}
+
+// locations caches loaded time zones, as functions are evaluated on each document.
+var locations sync.Map
+
+func loadLocation(name string, param int, zone string) (*time.Location, error) {
+	if location, ok := locations.Load(zone); ok {
+		return location.(*time.Location), nil
+	}
+	location, err := time.LoadLocation(zone)
+	if err != nil {
+		return nil, fmt.Errorf(`param %d of "%s" function must be a time zone, got "%s"`, param, name, zone)
+	}
+	locations.Store(zone, location)
+	return location, nil
+}
+
+func unsupportedDatePart(name string, part string) error {
+	return fmt.Errorf(`unsupported date part "%s" of "%s" function`, part, name)
+}
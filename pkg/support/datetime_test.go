@@ -0,0 +1,83 @@
+package support
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDateFunctions(t *testing.T) {
+	ts := time.Date(2024, 2, 29, 23, 30, 15, 123456789, time.UTC)
+	newYork, _ := time.LoadLocation("America/New_York")
+	kolkata, _ := time.LoadLocation("Asia/Kolkata")
+	tests := []struct {
+		name     string
+		params   []interface{}
+		expected interface{}
+	}{
+		{"DATE_TRUNC", []interface{}{ts, "day"}, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"DATE_TRUNC", []interface{}{ts, "MONTH"}, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"DATE_TRUNC", []interface{}{ts, "QUARTER"}, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"DATE_TRUNC", []interface{}{ts, "WEEK"}, time.Date(2024, 2, 25, 0, 0, 0, 0, time.UTC)},
+		{"DATE_TRUNC", []interface{}{ts, "DAY", "America/New_York"}, time.Date(2024, 2, 29, 0, 0, 0, 0, newYork)},
+		{"EXTRACT", []interface{}{"YEAR", ts}, float64(2024)},
+		{"EXTRACT", []interface{}{"DAYOFWEEK", ts}, float64(5)},
+		{"EXTRACT", []interface{}{"DAYOFYEAR", ts}, float64(60)},
+		{"EXTRACT", []interface{}{"MILLISECOND", ts}, float64(123)},
+		{"EXTRACT", []interface{}{"HOUR", ts, "Asia/Kolkata"}, float64(5)},
+		{"EXTRACT", []interface{}{"YEAR", nil}, nil},
+		{"FORMAT_TIMESTAMP", []interface{}{"%Y-%m-%d %H:%M:%S %%", ts}, "2024-02-29 23:30:15 %"},
+		{"FORMAT_TIMESTAMP", []interface{}{"%F %R %Z", ts, "America/New_York"}, "2024-02-29 18:30 EST"},
+		{"TIMESTAMP_ADD", []interface{}{ts, float64(1), "DAY"}, time.Date(2024, 3, 1, 23, 30, 15, 123456789, time.UTC)},
+		{"TIMESTAMP_SUB", []interface{}{ts, 1, "YEAR"}, time.Date(2023, 3, 1, 23, 30, 15, 123456789, time.UTC)},
+		{"TIMESTAMP_DIFF", []interface{}{ts, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), "DAY"}, float64(28)},
+		{"TIMESTAMP_DIFF", []interface{}{time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), ts, "HOUR"}, float64(-695)},
+		{"UNIX_SECONDS", []interface{}{time.Unix(1700000000, 999999999)}, float64(1700000000)},
+		{"UNIX_MILLIS", []interface{}{time.Unix(1700000000, 999999999)}, float64(1700000000999)},
+		{"TIMESTAMP_SECONDS", []interface{}{int64(1700000000)}, time.Unix(1700000000, 0).UTC()},
+		{"TIMESTAMP_MILLIS", []interface{}{int64(1700000000123)}, time.Unix(1700000000, 123000000).UTC()},
+		{"CONVERT_TZ", []interface{}{ts, "Asia/Kolkata"}, time.Date(2024, 3, 1, 5, 0, 15, 123456789, kolkata)},
+		{"CONVERT_TZ", []interface{}{ts, "America/New_York", "UTC"}, time.Date(2024, 3, 1, 4, 30, 15, 123456789, time.UTC)},
+	}
+	for _, tt := range tests {
+		actual, err := ExecFunc(tt.name, tt.params)
+		if err != nil {
+			t.Errorf("%s%v: %v", tt.name, tt.params, err)
+			continue
+		}
+		if expected, ok := tt.expected.(time.Time); ok {
+			if actual, ok := actual.(time.Time); !ok || !actual.Equal(expected) || actual.Location().String() != expected.Location().String() {
+				t.Errorf("%s%v: expected %v, actual %v", tt.name, tt.params, expected, actual)
+			}
+		} else if !reflect.DeepEqual(actual, tt.expected) {
+			t.Errorf("%s%v: expected %#v, actual %#v", tt.name, tt.params, tt.expected, actual)
+		}
+	}
+
+	for _, call := range []struct {
+		name   string
+		params []interface{}
+	}{
+		{"DATE_TRUNC", []interface{}{ts, "FORTNIGHT"}},
+		{"DATE_TRUNC", []interface{}{"2024-01-01", "DAY"}},
+		{"EXTRACT", []interface{}{"YEAR", ts, "Mars/Olympus"}},
+		{"FORMAT_TIMESTAMP", []interface{}{"%K", ts}},
+		{"TIMESTAMP_ADD", []interface{}{ts, float64(1.5), "DAY"}},
+		{"TIMESTAMP_DIFF", []interface{}{ts, ts, "MONTH"}},
+	} {
+		if _, err := ExecFunc(call.name, call.params); err == nil {
+			t.Errorf("%s%v: expected error", call.name, call.params)
+		}
+	}
+}
+
+func TestNow(t *testing.T) {
+	kolkata, _ := time.LoadLocation("Asia/Kolkata")
+	defer func(original func() time.Time) { clock = original }(clock)
+	clock = func() time.Time {
+		return time.Date(2024, 3, 1, 5, 30, 0, 0, kolkata)
+	}
+	if actual, err := ExecFunc("NOW", nil); err != nil || actual != time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC) {
+		t.Errorf("NOW(): expected 2024-03-01 00:00:00 UTC, actual %v, %v", actual, err)
+	}
+}
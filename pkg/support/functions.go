@@ -2,69 +2,249 @@ package support
 
 import (
 	"fmt"
-	"github.com/Knetic/govaluate"
 	"reflect"
+	"regexp"
 	"strings"
+	"sync"
 )
 
+// Function computes the result of a SQL function from its arguments.
 type Function func(data []interface{}) (interface{}, error)
 
+// VariadicParams is the maximum number of params of functions accepting any number of params.
+const VariadicParams = -1
+
+// AnyType matches params of any type in function signatures.
+const AnyType = "any"
+
+// NumberType matches integer and double params in function signatures.
+const NumberType = "number"
+
 type FunctionRegistration struct {
-	function       Function
-	requiredParams int
+	function  Function
+	minParams int
+	maxParams int
+	// types of params, the last type applies to all remaining params. Not checked if empty
+	paramTypes []string
+	// volatile functions return different results on each call, e.g. NOW()
+	volatile bool
+}
+
+// Functions is a registry of functions callable in SQL queries. Functions registered
+// in a registry hide functions of the same name in its parent registry.
+type Functions struct {
+	parent    *Functions
+	mu        sync.RWMutex
+	functions map[string]*FunctionRegistration
 }
 
-var functions = map[string]*FunctionRegistration{}
+var functionNameRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z_0-9]*$`)
+
+// globalFunctions holds built-in functions and functions registered globally.
+var globalFunctions = &Functions{functions: map[string]*FunctionRegistration{}}
 
 func init() {
-	functions = map[string]*FunctionRegistration{
-		"LENGTH": &FunctionRegistration{
-			function:       Length,
-			requiredParams: 1,
-		},
+	mustRegister("LENGTH", nullable(Length), 1, 1)
+}
+
+func mustRegister(name string, function Function, minParams int, maxParams int, paramTypes ...string) {
+	if err := globalFunctions.Register(name, function, minParams, maxParams, paramTypes...); err != nil {
+		panic(err)
 	}
 }
 
-func ExecFunc(name string, data []interface{}) (interface{}, error) {
-	err := ValidateFunc(name, data)
-	if err != nil {
-		return nil, err
+func mustRegisterVolatile(name string, function Function, minParams int, maxParams int, paramTypes ...string) {
+	if err := globalFunctions.RegisterVolatile(name, function, minParams, maxParams, paramTypes...); err != nil {
+		panic(err)
 	}
-	return functions[strings.ToUpper(name)].function(data)
 }
 
-func ValidateFunc(name string, params interface{}) error {
-	name = strings.ToUpper(name)
-	funRegistration := functions[name]
-	if funRegistration == nil {
-		return fmt.Errorf(`unknown function "%s"`, name)
+// GlobalFunctions returns the registry of functions available to all queries.
+func GlobalFunctions() *Functions {
+	return globalFunctions
+}
+
+// NewFunctions returns an empty registry on top of global functions.
+func NewFunctions() *Functions {
+	return &Functions{parent: globalFunctions, functions: map[string]*FunctionRegistration{}}
+}
+
+// Register adds the function accepting minParams to maxParams params, or any number of params
+// from minParams if maxParams is VariadicParams. Types of params are checked when paramTypes is
+// not empty, the last type applying to all remaining params. Types are as returned by
+// FirestoreType, NumberType or AnyType. NULL params match any type.
+func (f *Functions) Register(name string, function Function, minParams int, maxParams int, paramTypes ...string) error {
+	return f.register(name, function, false, minParams, maxParams, paramTypes)
+}
+
+// RegisterVolatile adds the function like Register, for functions returning different results
+// on each call, e.g. reading the clock. Prepared statements call them on each execution.
+func (f *Functions) RegisterVolatile(name string, function Function, minParams int, maxParams int, paramTypes ...string) error {
+	return f.register(name, function, true, minParams, maxParams, paramTypes)
+}
+
+func (f *Functions) register(name string, function Function, volatile bool, minParams int, maxParams int, paramTypes []string) error {
+	if !functionNameRegex.MatchString(name) {
+		return fmt.Errorf(`invalid function name "%s"`, name)
+	}
+	if function == nil {
+		return fmt.Errorf(`function "%s" is nil`, name)
 	}
-	switch v := params.(type) {
-	case []interface{}:
-		if len(v) < funRegistration.requiredParams {
-			return fmt.Errorf(`insufficient params to "%s" function. expects %d params`, name, funRegistration.requiredParams)
+	if minParams < 0 || (maxParams != VariadicParams && maxParams < minParams) {
+		return fmt.Errorf(`invalid number of params %d to %d of function "%s"`, minParams, maxParams, name)
+	}
+	for _, paramType := range paramTypes {
+		if !isParamType(paramType) {
+			return fmt.Errorf(`unknown param type "%s" of function "%s"`, paramType, name)
 		}
 	}
-
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.functions[strings.ToUpper(name)] = &FunctionRegistration{
+		function:   function,
+		minParams:  minParams,
+		maxParams:  maxParams,
+		paramTypes: paramTypes,
+		volatile:   volatile,
+	}
 	return nil
 }
 
-func Length(data []interface{}) (result interface{}, err error) {
-	defer func() {
-		if e := recover(); e != nil {
-			result = nil
-			err = fmt.Errorf(`LENGTH of type "%v" is not supported`, e.(*reflect.ValueError).Kind)
+func (f *Functions) lookup(name string) *FunctionRegistration {
+	if f == nil {
+		f = globalFunctions
+	}
+	for ; f != nil; f = f.parent {
+		f.mu.RLock()
+		registration := f.functions[strings.ToUpper(name)]
+		f.mu.RUnlock()
+		if registration != nil {
+			return registration
 		}
-	}()
-	return float64(reflect.ValueOf(data[0]).Len()), nil
+	}
+	return nil
+}
+
+// Exists reports whether the function is registered.
+func (f *Functions) Exists(name string) bool {
+	return f.lookup(name) != nil
+}
+
+// IsVolatile reports whether the function is registered as volatile.
+func (f *Functions) IsVolatile(name string) bool {
+	registration := f.lookup(name)
+	return registration != nil && registration.volatile
+}
+
+// ValidateParams checks the number of params passed to the function.
+func (f *Functions) ValidateParams(name string, count int) error {
+	funRegistration := f.lookup(name)
+	if funRegistration == nil {
+		return fmt.Errorf(`unknown function "%s"`, strings.ToUpper(name))
+	}
+	return funRegistration.validateCount(strings.ToUpper(name), count)
+}
+
+// Bind returns the function validating its params and calling it, to call
+// the function repeatedly without looking it up.
+func (f *Functions) Bind(name string) (Function, error) {
+	funRegistration := f.lookup(name)
+	if funRegistration == nil {
+		return nil, fmt.Errorf(`unknown function "%s"`, strings.ToUpper(name))
+	}
+	name = strings.ToUpper(name)
+	return func(params []interface{}) (interface{}, error) {
+		return funRegistration.call(name, params)
+	}, nil
+}
+
+// Exec calls the function with the params after validating them.
+func (f *Functions) Exec(name string, params []interface{}) (interface{}, error) {
+	funRegistration := f.lookup(name)
+	if funRegistration == nil {
+		return nil, fmt.Errorf(`unknown function "%s"`, strings.ToUpper(name))
+	}
+	return funRegistration.call(strings.ToUpper(name), params)
 }
 
-func GetEvalFunctions() map[string]govaluate.ExpressionFunction {
-	result := map[string]govaluate.ExpressionFunction{}
-	for name, fun := range functions {
-		result[name] = func(args ...interface{}) (interface{}, error) {
-			return fun.function(args)
+func (funRegistration *FunctionRegistration) validateCount(name string, count int) error {
+	if count < funRegistration.minParams {
+		return fmt.Errorf(`insufficient params to "%s" function. expects %s params`, name, funRegistration.arity())
+	}
+	if funRegistration.maxParams != VariadicParams && count > funRegistration.maxParams {
+		return fmt.Errorf(`too many params to "%s" function. expects %s params`, name, funRegistration.arity())
+	}
+	return nil
+}
+
+func (funRegistration *FunctionRegistration) arity() string {
+	switch {
+	case funRegistration.maxParams == VariadicParams:
+		return fmt.Sprintf("at least %d", funRegistration.minParams)
+	case funRegistration.minParams == funRegistration.maxParams:
+		return fmt.Sprint(funRegistration.minParams)
+	}
+	return fmt.Sprintf("%d to %d", funRegistration.minParams, funRegistration.maxParams)
+}
+
+func (funRegistration *FunctionRegistration) call(name string, params []interface{}) (interface{}, error) {
+	if err := funRegistration.validateCount(name, len(params)); err != nil {
+		return nil, err
+	}
+	if paramTypes := funRegistration.paramTypes; len(paramTypes) > 0 {
+		for idx, param := range params {
+			paramType := paramTypes[len(paramTypes)-1]
+			if idx < len(paramTypes) {
+				paramType = paramTypes[idx]
+			}
+			if !matchesParamType(param, paramType) {
+				return nil, fmt.Errorf(`param %d of "%s" function must be %s, got %s`, idx+1, name, paramType, FirestoreType(param))
+			}
 		}
 	}
-	return result
+	return funRegistration.function(params)
+}
+
+func isParamType(paramType string) bool {
+	switch paramType {
+	case AnyType, NumberType, "boolean", "integer", "double", "string", "bytes", "timestamp", "reference", "geopoint", "array", "map":
+		return true
+	}
+	return false
+}
+
+func matchesParamType(param interface{}, paramType string) bool {
+	if param == nil || paramType == AnyType {
+		return true
+	}
+	actual := FirestoreType(param)
+	if paramType == NumberType {
+		return actual == "integer" || actual == "double"
+	}
+	return actual == paramType
+}
+
+// ExecFunc calls the global function with the params.
+func ExecFunc(name string, data []interface{}) (interface{}, error) {
+	return globalFunctions.Exec(name, data)
+}
+
+// ValidateFunc checks the global function accepts the params.
+func ValidateFunc(name string, params interface{}) error {
+	if v, ok := params.([]interface{}); ok {
+		return globalFunctions.ValidateParams(name, len(v))
+	}
+	if !globalFunctions.Exists(name) {
+		return fmt.Errorf(`unknown function "%s"`, strings.ToUpper(name))
+	}
+	return nil
+}
+
+func Length(data []interface{}) (interface{}, error) {
+	value := reflect.ValueOf(data[0])
+	switch value.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return float64(value.Len()), nil
+	}
+	return nil, fmt.Errorf(`LENGTH of type "%v" is not supported`, value.Kind())
 }
@@ -0,0 +1,62 @@
+package support
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFunctions(t *testing.T) {
+	concat := func(data []interface{}) (interface{}, error) {
+		var parts []string
+		for _, val := range data {
+			parts = append(parts, val.(string))
+		}
+		return strings.Join(parts, ""), nil
+	}
+	functions := NewFunctions()
+	if err := functions.Register("join_all", concat, 1, VariadicParams, "string"); err != nil {
+		t.Fatal(err)
+	}
+	if err := functions.Register("bad-name", concat, 0, 0); err == nil {
+		t.Error("Register(bad-name): expected error")
+	}
+
+	if actual, err := functions.Exec("JOIN_ALL", []interface{}{"a", "b", "c"}); err != nil || actual != "abc" {
+		t.Errorf("JOIN_ALL(a, b, c): expected abc, actual %v, %v", actual, err)
+	}
+	if _, err := functions.Exec("join_all", []interface{}{"a", float64(1)}); err == nil {
+		t.Error("JOIN_ALL(a, 1): expected type error")
+	}
+	if err := functions.ValidateParams("join_all", 0); err == nil {
+		t.Error("JOIN_ALL(): expected error on insufficient params")
+	}
+	if err := functions.ValidateParams("length", 2); err == nil {
+		t.Error("LENGTH(a, b): expected error on too many params")
+	}
+	if GlobalFunctions().Exists("join_all") {
+		t.Error("JOIN_ALL registered in a scoped registry must not be global")
+	}
+
+	length, err := functions.Bind("length")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual, err := length([]interface{}{"abcd"}); err != nil || actual != float64(4) {
+		t.Errorf("LENGTH(abcd): expected 4, actual %v, %v", actual, err)
+	}
+	if actual, err := length([]interface{}{nil}); err != nil || actual != nil {
+		t.Errorf("LENGTH(NULL): expected NULL, actual %v, %v", actual, err)
+	}
+	if _, err := functions.Bind("missing"); err == nil {
+		t.Error("Bind(missing): expected error on unknown function")
+	}
+
+	if err := functions.RegisterVolatile("tick", concat, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	for name, expected := range map[string]bool{"TICK": true, "now": true, "current_timestamp": true, "join_all": false, "length": false, "missing": false} {
+		if actual := functions.IsVolatile(name); actual != expected {
+			t.Errorf("IsVolatile(%s): expected %v, actual %v", name, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package support
+
+import (
+	"container/list"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+func init() {
+	mustRegister("UPPER", nullable(Upper), 1, 1, "string")
+	mustRegister("LOWER", nullable(Lower), 1, 1, "string")
+	mustRegister("TRIM", nullable(Trim), 1, 2, "string")
+	mustRegister("SUBSTR", nullable(Substr), 2, 3, "string", NumberType)
+	mustRegister("SUBSTRING", nullable(Substr), 2, 3, "string", NumberType)
+	mustRegister("CONCAT", nullable(Concat), 1, VariadicParams, AnyType)
+	mustRegister("REPLACE", nullable(Replace), 3, 3, "string")
+	mustRegister("SPLIT", nullable(Split), 1, 2, "string")
+	mustRegister("STARTS_WITH", nullable(StartsWith), 2, 2, "string")
+	mustRegister("ENDS_WITH", nullable(EndsWith), 2, 2, "string")
+	mustRegister("REGEXP_EXTRACT", nullable(RegexpExtract), 2, 2, "string")
+	mustRegister("REGEXP_CONTAINS", nullable(RegexpContains), 2, 2, "string")
+	mustRegister("LPAD", nullable(LPad), 2, 3, "string", NumberType, "string")
+	mustRegister("RPAD", nullable(RPad), 2, 3, "string", NumberType, "string")
+}
+
+// nullable makes the function return NULL when any of its params is NULL.
+func nullable(function Function) Function {
+	return func(data []interface{}) (interface{}, error) {
+		for _, param := range data {
+			if param == nil {
+				return nil, nil
+			}
+		}
+		return function(data)
+	}
+}
+
+func Upper(data []interface{}) (interface{}, error) {
+	return strings.ToUpper(data[0].(string)), nil
+}
+
+func Lower(data []interface{}) (interface{}, error) {
+	return strings.ToLower(data[0].(string)), nil
+}
+
+// Trim removes leading and trailing whitespace, or characters of the second param.
+func Trim(data []interface{}) (interface{}, error) {
+	if len(data) == 2 {
+		return strings.Trim(data[0].(string), data[1].(string)), nil
+	}
+	return strings.TrimSpace(data[0].(string)), nil
+}
+
+// Substr returns the substring from the 1-based position, counted from the end
+// when negative, with at most the length of the third param.
+func Substr(data []interface{}) (interface{}, error) {
+	runes := []rune(data[0].(string))
+	position, err := intParam("SUBSTR", data, 1)
+	if err != nil {
+		return nil, err
+	}
+	start := position - 1
+	if position <= 0 {
+		start = len(runes) + position
+		if position == 0 || start < 0 {
+			start = 0
+		}
+	}
+	if start > len(runes) {
+		start = len(runes)
+	}
+	end := len(runes)
+	if len(data) == 3 {
+		length, err := intParam("SUBSTR", data, 2)
+		if err != nil {
+			return nil, err
+		}
+		if length < 0 {
+			return nil, fmt.Errorf(`param 3 of "SUBSTR" function must not be negative, got %d`, length)
+		}
+		if start+length < end {
+			end = start + length
+		}
+	}
+	return string(runes[start:end]), nil
+}
+
+// Concat concatenates strings, numbers and booleans.
+func Concat(data []interface{}) (interface{}, error) {
+	var result strings.Builder
+	for idx, param := range data {
+		switch param := param.(type) {
+		case string:
+			result.WriteString(param)
+		case float64:
+			result.WriteString(strconv.FormatFloat(param, 'f', -1, 64))
+		case int64:
+			result.WriteString(strconv.FormatInt(param, 10))
+		case bool:
+			result.WriteString(strconv.FormatBool(param))
+		default:
+			return nil, fmt.Errorf(`param %d of "CONCAT" function must be string, got %s`, idx+1, FirestoreType(param))
+		}
+	}
+	return result.String(), nil
+}
+
+func Replace(data []interface{}) (interface{}, error) {
+	return strings.ReplaceAll(data[0].(string), data[1].(string), data[2].(string)), nil
+}
+
+// Split splits the string into an array by the delimiter, "," by default,
+// or into characters when the delimiter is empty.
+func Split(data []interface{}) (interface{}, error) {
+	delimiter := ","
+	if len(data) == 2 {
+		delimiter = data[1].(string)
+	}
+	parts := strings.Split(data[0].(string), delimiter)
+	result := make([]interface{}, len(parts))
+	for idx, part := range parts {
+		result[idx] = part
+	}
+	return result, nil
+}
+
+func StartsWith(data []interface{}) (interface{}, error) {
+	return strings.HasPrefix(data[0].(string), data[1].(string)), nil
+}
+
+func EndsWith(data []interface{}) (interface{}, error) {
+	return strings.HasSuffix(data[0].(string), data[1].(string)), nil
+}
+
+// RegexpExtract returns the first match of the pattern, or of its capturing group
+// if it has one, and NULL when the pattern doesn't match.
+func RegexpExtract(data []interface{}) (interface{}, error) {
+	regex, err := compileRegexp("REGEXP_EXTRACT", data[1].(string))
+	if err != nil {
+		return nil, err
+	}
+	if regex.NumSubexp() > 1 {
+		return nil, fmt.Errorf(`param 2 of "REGEXP_EXTRACT" function must have at most one capturing group, got %d`, regex.NumSubexp())
+	}
+	match := regex.FindStringSubmatch(data[0].(string))
+	if match == nil {
+		return nil, nil
+	}
+	return match[len(match)-1], nil
+}
+
+func RegexpContains(data []interface{}) (interface{}, error) {
+	regex, err := compileRegexp("REGEXP_CONTAINS", data[1].(string))
+	if err != nil {
+		return nil, err
+	}
+	return regex.MatchString(data[0].(string)), nil
+}
+
+// LPad pads the string on the left to the length with the third param, a space by default.
+// Longer strings are truncated to the length.
+func LPad(data []interface{}) (interface{}, error) {
+	return pad("LPAD", data, true)
+}
+
+// RPad pads the string on the right to the length with the third param, a space by default.
+// Longer strings are truncated to the length.
+func RPad(data []interface{}) (interface{}, error) {
+	return pad("RPAD", data, false)
+}
+
+func pad(name string, data []interface{}, left bool) (interface{}, error) {
+	runes := []rune(data[0].(string))
+	length, err := intParam(name, data, 1)
+	if err != nil {
+		return nil, err
+	}
+	if length < 0 {
+		return nil, fmt.Errorf(`param 2 of "%s" function must not be negative, got %d`, name, length)
+	}
+	padding := " "
+	if len(data) == 3 {
+		padding = data[2].(string)
+	}
+	if len(runes) >= length {
+		return string(runes[:length]), nil
+	}
+	if padding == "" {
+		return nil, fmt.Errorf(`param 3 of "%s" function must not be empty`, name)
+	}
+	count := length - len(runes)
+	fill := []rune(strings.Repeat(padding, count/utf8.RuneCountInString(padding)+1))[:count]
+	if left {
+		return string(fill) + string(runes), nil
+	}
+	return string(runes) + string(fill), nil
+}
+
+// intParam returns the param at the index as an integer.
+func intParam(name string, data []interface{}, idx int) (int, error) {
+	switch val := data[idx].(type) {
+	case int:
+		return val, nil
+	case int64:
+		return int(val), nil
+	case float64:
+		if val == math.Trunc(val) && math.Abs(val) <= math.MaxInt32 {
+			return int(val), nil
+		}
+	}
+	return 0, fmt.Errorf(`param %d of "%s" function must be an integer, got %v`, idx+1, name, data[idx])
+}
+
+// maxCachedRegexps bounds the cache of compiled patterns, patterns read from documents
+// being as many as the documents.
+const maxCachedRegexps = 256
+
+// regexpCache keeps the most recently used compiled patterns, as functions are evaluated
+// on each document.
+type regexpCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type cachedRegexp struct {
+	pattern string
+	regex   *regexp.Regexp
+}
+
+var regexps = newRegexpCache(maxCachedRegexps)
+
+func newRegexpCache(size int) *regexpCache {
+	return &regexpCache{size: size, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+func (c *regexpCache) get(pattern string) *regexp.Regexp {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[pattern]; ok {
+		c.order.MoveToFront(elem)
+		return elem.Value.(*cachedRegexp).regex
+	}
+	return nil
+}
+
+func (c *regexpCache) put(pattern string, regex *regexp.Regexp) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[pattern]; ok {
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[pattern] = c.order.PushFront(&cachedRegexp{pattern: pattern, regex: regex})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cachedRegexp).pattern)
+	}
+}
+
+func compileRegexp(name string, pattern string) (*regexp.Regexp, error) {
+	if regex := regexps.get(pattern); regex != nil {
+		return regex, nil
+	}
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf(`param 2 of "%s" function must be a valid regular expression: %v`, name, err)
+	}
+	regexps.put(pattern, regex)
+	return regex, nil
+}
@@ -0,0 +1,79 @@
+package support
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestStringFunctions(t *testing.T) {
+	tests := []struct {
+		name     string
+		params   []interface{}
+		expected interface{}
+	}{
+		{"UPPER", []interface{}{"abc"}, "ABC"},
+		{"LOWER", []interface{}{"ABC"}, "abc"},
+		{"UPPER", []interface{}{nil}, nil},
+		{"TRIM", []interface{}{"  abc \n"}, "abc"},
+		{"TRIM", []interface{}{"xxabcx", "x"}, "abc"},
+		{"SUBSTR", []interface{}{"héllo", float64(2)}, "éllo"},
+		{"SUBSTR", []interface{}{"hello", float64(2), float64(3)}, "ell"},
+		{"SUBSTR", []interface{}{"hello", float64(-3)}, "llo"},
+		{"SUBSTR", []interface{}{"hello", int64(10)}, ""},
+		{"CONCAT", []interface{}{"id-", float64(7), "-", int64(3)}, "id-7-3"},
+		{"CONCAT", []interface{}{"a", nil}, nil},
+		{"REPLACE", []interface{}{"a-b-c", "-", "+"}, "a+b+c"},
+		{"SPLIT", []interface{}{"a,b"}, []interface{}{"a", "b"}},
+		{"SPLIT", []interface{}{"a b", " "}, []interface{}{"a", "b"}},
+		{"STARTS_WITH", []interface{}{"hello", "he"}, true},
+		{"ENDS_WITH", []interface{}{"hello", "he"}, false},
+		{"REGEXP_EXTRACT", []interface{}{"foo@example.com", "@(.+)$"}, "example.com"},
+		{"REGEXP_EXTRACT", []interface{}{"foo", "[0-9]+"}, nil},
+		{"REGEXP_CONTAINS", []interface{}{"foo123", "[0-9]+"}, true},
+		{"LPAD", []interface{}{"7", float64(3), "0"}, "007"},
+		{"RPAD", []interface{}{"ab", float64(5), "xy"}, "abxyx"},
+		{"LPAD", []interface{}{"abcdef", float64(3)}, "abc"},
+	}
+	for _, tt := range tests {
+		actual, err := ExecFunc(tt.name, tt.params)
+		if err != nil {
+			t.Errorf("%s%v: %v", tt.name, tt.params, err)
+		} else if !reflect.DeepEqual(actual, tt.expected) {
+			t.Errorf("%s%v: expected %#v, actual %#v", tt.name, tt.params, tt.expected, actual)
+		}
+	}
+
+	for _, call := range []struct {
+		name   string
+		params []interface{}
+	}{
+		{"UPPER", []interface{}{float64(1)}},
+		{"SUBSTR", []interface{}{"abc", float64(1.5)}},
+		{"SUBSTR", []interface{}{"abc", float64(1), float64(-1)}},
+		{"REGEXP_CONTAINS", []interface{}{"abc", "("}},
+		{"LPAD", []interface{}{"abc", float64(5), ""}},
+		{"CONCAT", []interface{}{"abc", []interface{}{}}},
+	} {
+		if _, err := ExecFunc(call.name, call.params); err == nil {
+			t.Errorf("%s%v: expected error", call.name, call.params)
+		}
+	}
+}
+
+func TestRegexpCache(t *testing.T) {
+	cache := newRegexpCache(2)
+	for _, pattern := range []string{"a", "b", "a", "c"} {
+		if cache.get(pattern) == nil {
+			cache.put(pattern, regexp.MustCompile(pattern))
+		}
+	}
+	if cache.order.Len() != 2 || len(cache.entries) != 2 {
+		t.Fatalf("expected 2 cached patterns, actual %d", len(cache.entries))
+	}
+	for pattern, expected := range map[string]bool{"a": true, "b": false, "c": true} {
+		if actual := cache.get(pattern) != nil; actual != expected {
+			t.Errorf("%s: expected cached %v, actual %v", pattern, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package support
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// timestampLayouts are the accepted layouts of timestamp literals, tried in order.
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// ParseTimestamp parses a timestamp literal. Timestamps without
+// a timezone are considered to be in UTC.
+func ParseTimestamp(value string) (time.Time, error) {
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, strings.TrimSpace(value)); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf(`invalid timestamp "%s", expected RFC 3339 format like "2024-01-01T00:00:00Z"`, value)
+}
+
+// ParseDate parses a date literal in "YYYY-MM-DD" format as midnight UTC.
+func ParseDate(value string) (time.Time, error) {
+	t, err := time.Parse("2006-01-02", strings.TrimSpace(value))
+	if err != nil {
+		return time.Time{}, fmt.Errorf(`invalid date "%s", expected format like "2024-01-01"`, value)
+	}
+	return t, nil
+}
+
+// AddInterval adds amount of the interval unit, e.g. DAY or MONTH, to the timestamp.
+func AddInterval(t time.Time, amount int, unit string) (time.Time, error) {
+	switch strings.ToUpper(unit) {
+	case "MICROSECOND":
+		return t.Add(time.Duration(amount) * time.Microsecond), nil
+	case "MILLISECOND":
+		return t.Add(time.Duration(amount) * time.Millisecond), nil
+	case "SECOND":
+		return t.Add(time.Duration(amount) * time.Second), nil
+	case "MINUTE":
+		return t.Add(time.Duration(amount) * time.Minute), nil
+	case "HOUR":
+		return t.Add(time.Duration(amount) * time.Hour), nil
+	case "DAY":
+		return t.AddDate(0, 0, amount), nil
+	case "WEEK":
+		return t.AddDate(0, 0, 7*amount), nil
+	case "MONTH":
+		return t.AddDate(0, amount, 0), nil
+	case "QUARTER":
+		return t.AddDate(0, 3*amount, 0), nil
+	case "YEAR":
+		return t.AddDate(amount, 0, 0), nil
+	}
+	return t, fmt.Errorf(`unsupported interval unit "%s"`, unit)
+}
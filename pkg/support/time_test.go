@@ -0,0 +1,46 @@
+package support
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	expected := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, value := range []string{"2024-01-02T03:04:05Z", "2024-01-02T03:04:05", "2024-01-02 03:04:05", "2024-01-02T05:04:05+02:00"} {
+		actual, err := ParseTimestamp(value)
+		if err != nil {
+			t.Errorf("ParseTimestamp(%s): %v", value, err)
+		} else if !actual.Equal(expected) {
+			t.Errorf("ParseTimestamp(%s): expected %v, actual %v", value, expected, actual)
+		}
+	}
+	if _, err := ParseTimestamp("yesterday"); err == nil {
+		t.Error("ParseTimestamp(yesterday): expected error")
+	}
+}
+
+func TestAddInterval(t *testing.T) {
+	start := time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		amount   int
+		unit     string
+		expected time.Time
+	}{
+		{-7, "day", time.Date(2024, 1, 24, 12, 0, 0, 0, time.UTC)},
+		{2, "HOUR", time.Date(2024, 1, 31, 14, 0, 0, 0, time.UTC)},
+		{1, "week", time.Date(2024, 2, 7, 12, 0, 0, 0, time.UTC)},
+		{-1, "year", time.Date(2023, 1, 31, 12, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		actual, err := AddInterval(start, tt.amount, tt.unit)
+		if err != nil {
+			t.Errorf("AddInterval(%d %s): %v", tt.amount, tt.unit, err)
+		} else if !actual.Equal(tt.expected) {
+			t.Errorf("AddInterval(%d %s): expected %v, actual %v", tt.amount, tt.unit, tt.expected, actual)
+		}
+	}
+	if _, err := AddInterval(start, 1, "fortnight"); err == nil {
+		t.Error("AddInterval(fortnight): expected error")
+	}
+}
@@ -0,0 +1,37 @@
+package support
+
+import (
+	"cloud.google.com/go/firestore"
+	"google.golang.org/genproto/googleapis/type/latlng"
+	"time"
+)
+
+// FirestoreType returns the Firestore value type name of a value
+// read from a document, e.g. "string", "timestamp" or "map".
+func FirestoreType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case int, int32, int64:
+		return "integer"
+	case float32, float64:
+		return "double"
+	case string:
+		return "string"
+	case []byte:
+		return "bytes"
+	case time.Time, *time.Time:
+		return "timestamp"
+	case *latlng.LatLng, latlng.LatLng:
+		return "geopoint"
+	case *firestore.DocumentRef:
+		return "reference"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "map"
+	}
+	return "unknown"
+}
@@ -0,0 +1,38 @@
+package util
+
+import (
+	"cloud.google.com/go/firestore"
+	vkit "cloud.google.com/go/firestore/apiv1"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/option"
+)
+
+// NewFireClient creates a Firestore client for the project, database
+// and credentials configured in the Context.
+func NewFireClient(fqlContext *Context) (*firestore.Client, error) {
+	ctx := context.Background()
+
+	var firestoreOptions []option.ClientOption
+	if len(fqlContext.ServiceAccount) > 0 {
+		if !json.Valid([]byte(fqlContext.ServiceAccount)) {
+			return nil, errors.New("invalid service account, it is expected to be a JSON")
+		}
+
+		creds, err := google.CredentialsFromJSON(ctx, []byte(fqlContext.ServiceAccount),
+			vkit.DefaultAuthScopes()...,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("ServiceAccount: %v", err)
+		}
+		firestoreOptions = append(firestoreOptions, option.WithCredentials(creds))
+	}
+
+	if len(fqlContext.DatabaseId) > 0 {
+		return firestore.NewClientWithDatabase(ctx, fqlContext.ProjectId, fqlContext.DatabaseId, firestoreOptions...)
+	}
+	return firestore.NewClient(ctx, fqlContext.ProjectId, firestoreOptions...)
+}
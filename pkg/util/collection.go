@@ -0,0 +1,105 @@
+package util
+
+import (
+	"cloud.google.com/go/firestore"
+	"strings"
+)
+
+// Collection is the source of documents queried by a statement.
+type Collection struct {
+	// Query over all documents of the collection
+	Query firestore.Query
+	// Ref is the referenced collection, nil for collection groups
+	Ref *firestore.CollectionRef
+	// ID of the collection or the collection group
+	ID string
+	// Parent is path of the document the collection or collection group is under,
+	// empty for root collections and collection groups across the database
+	Parent string
+}
+
+// ResolveCollection resolves the named collection.
+//
+// The name can be a root collection ID ("users") or a full collection path
+// with an odd number of segments ("users/u123/orders"). A collection ID enclosed
+// in square brackets refers to a collection group, either across the whole
+// database ("[orders]") or scoped to documents under a parent document
+// ("users/u123/[orders]").
+func ResolveCollection(fireClient *firestore.Client, name string) (*Collection, error) {
+	name = strings.Trim(strings.TrimSpace(name), "`")
+	segments := strings.Split(strings.Trim(name, "/"), "/")
+	for _, segment := range segments {
+		if segment == "" {
+			return nil, NewParseError(CodeInvalidArgument, name, `invalid collection path "%s", path segments can't be empty`, name)
+		}
+	}
+
+	last := segments[len(segments)-1]
+	if strings.HasPrefix(last, "[") && strings.HasSuffix(last, "]") {
+		groupName := strings.TrimPrefix(last, "[")
+		groupName = strings.TrimSuffix(groupName, "]")
+		query := fireClient.CollectionGroup(groupName).Query
+		parentPath := strings.Join(segments[:len(segments)-1], "/")
+		if parentPath == "" {
+			return &Collection{Query: query, ID: groupName}, nil
+		}
+		if len(segments[:len(segments)-1])%2 != 0 {
+			return nil, NewParseError(CodeInvalidArgument, parentPath, `invalid collection group parent "%s", expected a document path with an even number of segments`, parentPath)
+		}
+		// Documents are ordered by segments of their paths, compared as UTF-8 bytes. Documents
+		// of the group under the parent sort after the parent and before its next possible sibling,
+		// whose ID is the parent ID followed by U+0000, whatever characters their segments have
+		query = query.
+			Where(firestore.DocumentID, ">", fireClient.Doc(parentPath)).
+			Where(firestore.DocumentID, "<", fireClient.Doc(parentPath+"\x00"))
+		return &Collection{Query: query, ID: groupName, Parent: parentPath}, nil
+	}
+
+	if len(segments)%2 == 0 {
+		return nil, NewParseError(CodeInvalidArgument, name, `invalid collection path "%s" refers to a document, expected an odd number of segments`, name)
+	}
+	ref := fireClient.Collection(strings.Join(segments, "/"))
+	return &Collection{Query: ref.Query, Ref: ref, ID: ref.ID, Parent: strings.Join(segments[:len(segments)-1], "/")}, nil
+}
+
+// DocumentRef resolves a document by its ID in the collection, or by
+// its full path ("users/u123") when the ID contains a slash.
+// Documents of collection groups can only be resolved by full path,
+// and paths of documents in other collections are rejected.
+func (collection *Collection) DocumentRef(fireClient *firestore.Client, id string) (*firestore.DocumentRef, error) {
+	var ref *firestore.DocumentRef
+	if strings.Contains(id, "/") {
+		ref = fireClient.Doc(strings.Trim(id, "/"))
+	} else if collection.Ref != nil {
+		ref = collection.Ref.Doc(id)
+	} else {
+		return nil, NewParseError(CodeInvalidArgument, id, `document "%s" of a collection group must be referred by its full path`, id)
+	}
+	if ref == nil {
+		return nil, NewParseError(CodeInvalidArgument, id, `invalid document path "%s", expected an even number of path segments`, id)
+	}
+	if !collection.Contains(ref) {
+		return nil, NewParseError(CodeInvalidArgument, id, `document "%s" isn't in the queried collection`, id)
+	}
+	return ref, nil
+}
+
+// Contains reports whether the document belongs to the collection, or to the collection group
+// under its parent document.
+func (collection *Collection) Contains(ref *firestore.DocumentRef) bool {
+	if ref == nil || ref.Parent == nil {
+		return false
+	}
+	if collection.Ref != nil {
+		return ref.Parent.Path == collection.Ref.Path
+	}
+	if ref.Parent.ID != collection.ID {
+		return false
+	}
+	// paths of documents are relative to the database root
+	path := ref.Path
+	if idx := strings.Index(path, "/documents/"); idx >= 0 {
+		path = path[idx+len("/documents/"):]
+	}
+	return collection.Parent == "" || strings.HasPrefix(path, collection.Parent+"/")
+}
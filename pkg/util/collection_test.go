@@ -0,0 +1,80 @@
+package util
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"testing"
+)
+
+func TestResolveCollection(t *testing.T) {
+	t.Setenv("FIRESTORE_EMULATOR_HOST", "localhost:8765")
+	fireClient, err := firestore.NewClient(context.Background(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fireClient.Close()
+
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"users", true},
+		{"`users`", true},
+		{"[contacts]", true},
+		{"users/u123/orders", true},
+		{"users/u123/[orders]", true},
+		{"users/u123", false},
+		{"users//orders", false},
+		{"users/[orders]", false},
+	}
+	for _, tt := range tests {
+		_, err := ResolveCollection(fireClient, tt.name)
+		if tt.valid && err != nil {
+			t.Errorf("ResolveCollection(%s): unexpected error %v", tt.name, err)
+		} else if !tt.valid && err == nil {
+			t.Errorf("ResolveCollection(%s): expected error", tt.name)
+		}
+	}
+}
+
+func TestDocumentRef(t *testing.T) {
+	t.Setenv("FIRESTORE_EMULATOR_HOST", "localhost:8765")
+	fireClient, err := firestore.NewClient(context.Background(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fireClient.Close()
+
+	tests := []struct {
+		collection string
+		id         string
+		expected   string
+	}{
+		{"users", "1", "users/1"},
+		{"users", "users/1", "users/1"},
+		{"users", "orders/x", ""},
+		{"users", "users/1/orders/x", ""},
+		{"users/1/orders", "x", "users/1/orders/x"},
+		{"users/1/orders", "users/2/orders/x", ""},
+		{"[orders]", "users/2/orders/x", "users/2/orders/x"},
+		{"[orders]", "x", ""},
+		{"users/1/[orders]", "users/1/orders/x", "users/1/orders/x"},
+		{"users/1/[orders]", "users/10/orders/x", ""},
+		{"users/1/[orders]", "users/1/\U0001F6D2/a/orders/x", "users/1/\U0001F6D2/a/orders/x"},
+	}
+	for _, tt := range tests {
+		collection, err := ResolveCollection(fireClient, tt.collection)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ref, err := collection.DocumentRef(fireClient, tt.id)
+		switch {
+		case tt.expected == "" && err == nil:
+			t.Errorf("DocumentRef(%s, %s): expected error, got %s", tt.collection, tt.id, ref.Path)
+		case tt.expected != "" && err != nil:
+			t.Errorf("DocumentRef(%s, %s): unexpected error %v", tt.collection, tt.id, err)
+		case tt.expected != "" && ref.Path != fireClient.Doc(tt.expected).Path:
+			t.Errorf("DocumentRef(%s, %s): expected %s, actual %s", tt.collection, tt.id, tt.expected, ref.Path)
+		}
+	}
+}
@@ -1,7 +1,14 @@
 package util
 
+import (
+	"github.com/pgollangi/fireql/pkg/support"
+)
+
 type Context struct {
 	ProjectId      string
+	DatabaseId     string
 	ServiceAccount string
 	DefaultLimit   int
+	// Functions callable in queries, global functions if nil
+	Functions *support.Functions
 }
@@ -0,0 +1,125 @@
+package util
+
+import (
+	"fmt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrorCode identifies the reason of a ParseError or an UnsupportedError.
+type ErrorCode string
+
+const (
+	// CodeSyntaxError is reported when the query isn't valid SQL.
+	CodeSyntaxError ErrorCode = "SYNTAX_ERROR"
+	// CodeInvalidArgument is reported for invalid values, e.g. paths, literals or function arguments.
+	CodeInvalidArgument ErrorCode = "INVALID_ARGUMENT"
+	// CodeUnsupportedStatement is reported for statements other than SELECT, SHOW and DESCRIBE.
+	CodeUnsupportedStatement ErrorCode = "UNSUPPORTED_STATEMENT"
+	// CodeUnsupportedClause is reported for clauses FireQL can't translate, e.g. GROUP BY.
+	CodeUnsupportedClause ErrorCode = "UNSUPPORTED_CLAUSE"
+	// CodeUnsupportedExpression is reported for expressions FireQL can't evaluate.
+	CodeUnsupportedExpression ErrorCode = "UNSUPPORTED_EXPRESSION"
+	// CodeUnsupportedFunction is reported for unknown functions.
+	CodeUnsupportedFunction ErrorCode = "UNSUPPORTED_FUNCTION"
+	// CodeQueryLimitation is reported for queries exceeding Firestore query limitations.
+	CodeQueryLimitation ErrorCode = "QUERY_LIMITATION"
+)
+
+// ParseError is returned when the query is malformed.
+type ParseError struct {
+	Code    ErrorCode
+	Message string
+	// Fragment is the offending part of the query, if known.
+	Fragment string
+	// Position is the 1-based byte offset of the error in the query, 0 if unknown.
+	Position int
+}
+
+func (e *ParseError) Error() string {
+	return e.Message
+}
+
+// NewParseError returns a ParseError with a formatted message.
+func NewParseError(code ErrorCode, fragment string, format string, args ...interface{}) *ParseError {
+	return &ParseError{Code: code, Message: fmt.Sprintf(format, args...), Fragment: fragment}
+}
+
+var syntaxErrorRegex = regexp.MustCompile(`at position (\d+)(?: near '(.*)')?`)
+
+// NewSyntaxError returns a ParseError of the SQL parser error on the query, as parsed after
+// rewriting it for the parser. The parser reports positions in the parsed query, which are
+// mapped to the query by the fragment when it occurs once in the query, or else dropped.
+func NewSyntaxError(query string, parsed string, err error) *ParseError {
+	parseErr := &ParseError{Code: CodeSyntaxError, Message: err.Error()}
+	matches := syntaxErrorRegex.FindStringSubmatch(err.Error())
+	if matches == nil {
+		return parseErr
+	}
+	parseErr.Fragment = matches[2]
+	// The parser reports the position after the fragment
+	end, _ := strconv.Atoi(matches[1])
+	if start := end - len(parseErr.Fragment); query == parsed && start > 0 && start <= len(query)+1 &&
+		strings.HasPrefix(query[start-1:], parseErr.Fragment) {
+		parseErr.Position = start
+		return parseErr
+	}
+	if parseErr.Fragment != "" && strings.Count(query, parseErr.Fragment) == 1 {
+		parseErr.Position = strings.Index(query, parseErr.Fragment) + 1
+	}
+	location := fmt.Sprintf("near '%s'", parseErr.Fragment)
+	switch {
+	case parseErr.Position > 0:
+		location = fmt.Sprintf("at position %d %s", parseErr.Position, location)
+	case parseErr.Fragment == "":
+		location = "at the end of the query"
+	}
+	parseErr.Message = strings.Replace(err.Error(), matches[0], location, 1)
+	return parseErr
+}
+
+// UnsupportedError is returned when the query is valid SQL FireQL can't run on Firestore.
+type UnsupportedError struct {
+	Code    ErrorCode
+	Message string
+	// Fragment is the offending part of the query, if known.
+	Fragment string
+}
+
+func (e *UnsupportedError) Error() string {
+	return e.Message
+}
+
+// NewUnsupportedError returns an UnsupportedError with a formatted message.
+func NewUnsupportedError(code ErrorCode, fragment string, format string, args ...interface{}) *UnsupportedError {
+	return &UnsupportedError{Code: code, Message: fmt.Sprintf(format, args...), Fragment: fragment}
+}
+
+// FirestoreError is returned when Firestore fails to run the query.
+type FirestoreError struct {
+	// Code is the gRPC status code of the failure, e.g. codes.FailedPrecondition for a missing index.
+	Code codes.Code
+	Err  error
+	// MissingIndex is the composite index the query requires, when Firestore rejected it for a missing index.
+	MissingIndex *Index
+}
+
+func (e *FirestoreError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *FirestoreError) Unwrap() error {
+	return e.Err
+}
+
+// NewFirestoreError wraps the error returned by the Firestore client.
+func NewFirestoreError(err error) *FirestoreError {
+	firestoreErr := &FirestoreError{Code: status.Code(err), Err: err}
+	if firestoreErr.Code == codes.FailedPrecondition {
+		firestoreErr.MissingIndex = MissingIndex(err.Error())
+	}
+	return firestoreErr
+}
@@ -0,0 +1,61 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"github.com/xwb1989/sqlparser"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func TestNewSyntaxError(t *testing.T) {
+	tests := []struct {
+		query    string
+		parsed   string
+		fragment string
+		position int
+		message  string
+	}{
+		{query: "select * form users", fragment: "form", position: 10, message: "syntax error at position 14 near 'form'"},
+		{query: "select a, a a a from t", fragment: "a", position: 15, message: "syntax error at position 16 near 'a'"},
+		{query: "select * from users where", position: 26, message: "syntax error at position 26"},
+		{
+			query:    "select * from t where tags contains 'a' 'b'",
+			parsed:   "select * from t where array_contains(tags, 'a') 'b'",
+			fragment: "b",
+			position: 42,
+			message:  "syntax error at position 42 near 'b'",
+		},
+		{
+			query:    "select * from t where tags contains 'a' contains 'a'",
+			parsed:   "select * from t where array_contains(tags, 'a') contains 'a'",
+			fragment: "contains",
+			message:  "syntax error near 'contains'",
+		},
+		{
+			query:   "select * from t where tags contains 'a' and",
+			parsed:  "select * from t where array_contains(tags, 'a') and",
+			message: "syntax error at the end of the query",
+		},
+	}
+	for _, tt := range tests {
+		parsed := tt.parsed
+		if parsed == "" {
+			parsed = tt.query
+		}
+		_, err := sqlparser.Parse(parsed)
+		parseErr := NewSyntaxError(tt.query, parsed, err)
+		if parseErr.Code != CodeSyntaxError || parseErr.Fragment != tt.fragment || parseErr.Position != tt.position || parseErr.Message != tt.message {
+			t.Errorf("NewSyntaxError(%s): unexpected %+v", tt.query, parseErr)
+		}
+	}
+}
+
+func TestFirestoreError(t *testing.T) {
+	err := fmt.Errorf("query failed: %w", NewFirestoreError(status.Error(codes.FailedPrecondition, "The query requires an index")))
+	var firestoreErr *FirestoreError
+	if !errors.As(err, &firestoreErr) || firestoreErr.Code != codes.FailedPrecondition {
+		t.Errorf("errors.As(%v): expected FirestoreError with FailedPrecondition code", err)
+	}
+}
@@ -0,0 +1,113 @@
+package util
+
+import (
+	"cloud.google.com/go/firestore/apiv1/admin/adminpb"
+	"encoding/base64"
+	"google.golang.org/protobuf/proto"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Index is a composite index definition as in firestore.indexes.json.
+// See https://firebase.google.com/docs/reference/firestore/indexes
+type Index struct {
+	CollectionGroup string       `json:"collectionGroup"`
+	QueryScope      string       `json:"queryScope"`
+	Fields          []IndexField `json:"fields"`
+}
+
+// IndexField is a field of a composite index, either ordered or an array config.
+type IndexField struct {
+	FieldPath   string `json:"fieldPath"`
+	Order       string `json:"order,omitempty"`
+	ArrayConfig string `json:"arrayConfig,omitempty"`
+}
+
+// IndexConfig is the content of firestore.indexes.json deployed by the Firebase CLI.
+type IndexConfig struct {
+	Indexes        []*Index      `json:"indexes"`
+	FieldOverrides []interface{} `json:"fieldOverrides"`
+}
+
+// Index query scopes and field settings
+const (
+	QueryScopeCollection      = "COLLECTION"
+	QueryScopeCollectionGroup = "COLLECTION_GROUP"
+	OrderAscending            = "ASCENDING"
+	OrderDescending           = "DESCENDING"
+	ArrayConfigContains       = "CONTAINS"
+)
+
+// String describes the index, e.g. "users (COLLECTION): city ASCENDING, tags CONTAINS".
+func (index *Index) String() string {
+	fields := make([]string, len(index.Fields))
+	for idx, field := range index.Fields {
+		fields[idx] = field.FieldPath + " " + field.Order + field.ArrayConfig
+	}
+	return index.CollectionGroup + " (" + index.QueryScope + "): " + strings.Join(fields, ", ")
+}
+
+// Equal reports whether both indexes have the same definition.
+func (index *Index) Equal(other *Index) bool {
+	if index.CollectionGroup != other.CollectionGroup || index.QueryScope != other.QueryScope ||
+		len(index.Fields) != len(other.Fields) {
+		return false
+	}
+	for idx, field := range index.Fields {
+		if field != other.Fields[idx] {
+			return false
+		}
+	}
+	return true
+}
+
+// createIndexRegex matches the link to create the missing index in the error Firestore returns
+// when the query requires an index, capturing the index definition encoded in it.
+var createIndexRegex = regexp.MustCompile(`[?&]create_composite=([A-Za-z0-9_%=+/-]+)`)
+
+// MissingIndex returns the index Firestore reports as missing in a FailedPrecondition
+// error message, or nil when the message doesn't have one.
+func MissingIndex(message string) *Index {
+	matches := createIndexRegex.FindStringSubmatch(message)
+	if matches == nil {
+		return nil
+	}
+	encoded, err := url.QueryUnescape(matches[1])
+	if err != nil {
+		return nil
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		if data, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "=")); err != nil {
+			return nil
+		}
+	}
+	var adminIndex adminpb.Index
+	if err = proto.Unmarshal(data, &adminIndex); err != nil {
+		return nil
+	}
+
+	// Name is projects/{project}/databases/{database}/collectionGroups/{collection}/indexes/{index}
+	segments := strings.Split(adminIndex.GetName(), "/")
+	if len(segments) < 6 || segments[4] != "collectionGroups" {
+		return nil
+	}
+	index := &Index{CollectionGroup: segments[5], QueryScope: QueryScopeCollection}
+	if adminIndex.GetQueryScope() == adminpb.Index_COLLECTION_GROUP {
+		index.QueryScope = QueryScopeCollectionGroup
+	}
+	for _, field := range adminIndex.GetFields() {
+		indexField := IndexField{FieldPath: field.GetFieldPath()}
+		switch {
+		case field.GetArrayConfig() == adminpb.Index_IndexField_CONTAINS:
+			indexField.ArrayConfig = ArrayConfigContains
+		case field.GetOrder() == adminpb.Index_IndexField_DESCENDING:
+			indexField.Order = OrderDescending
+		default:
+			indexField.Order = OrderAscending
+		}
+		index.Fields = append(index.Fields, indexField)
+	}
+	return index
+}
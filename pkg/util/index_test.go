@@ -0,0 +1,40 @@
+package util
+
+import (
+	"cloud.google.com/go/firestore/apiv1/admin/adminpb"
+	"encoding/base64"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"testing"
+)
+
+func TestMissingIndex(t *testing.T) {
+	data, err := proto.Marshal(&adminpb.Index{
+		Name:       "projects/test/databases/(default)/collectionGroups/users/indexes/_",
+		QueryScope: adminpb.Index_COLLECTION,
+		Fields: []*adminpb.Index_IndexField{
+			{FieldPath: "tags", ValueMode: &adminpb.Index_IndexField_ArrayConfig_{ArrayConfig: adminpb.Index_IndexField_CONTAINS}},
+			{FieldPath: "age", ValueMode: &adminpb.Index_IndexField_Order_{Order: adminpb.Index_IndexField_DESCENDING}},
+			{FieldPath: "__name__", ValueMode: &adminpb.Index_IndexField_Order_{Order: adminpb.Index_IndexField_DESCENDING}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := "The query requires an index. You can create it here: https://console.firebase.google.com/v1/r/project/test/firestore/indexes?create_composite=" +
+		base64.StdEncoding.EncodeToString(data)
+	firestoreErr := NewFirestoreError(status.Error(codes.FailedPrecondition, message))
+
+	expected := &Index{CollectionGroup: "users", QueryScope: QueryScopeCollection, Fields: []IndexField{
+		{FieldPath: "tags", ArrayConfig: ArrayConfigContains},
+		{FieldPath: "age", Order: OrderDescending},
+		{FieldPath: "__name__", Order: OrderDescending},
+	}}
+	if firestoreErr.MissingIndex == nil || !firestoreErr.MissingIndex.Equal(expected) {
+		t.Errorf("MissingIndex(%s): expected %v, actual %v", message, expected, firestoreErr.MissingIndex)
+	}
+	if index := MissingIndex("The query requires an index"); index != nil {
+		t.Errorf("MissingIndex: expected nil, actual %v", index)
+	}
+}
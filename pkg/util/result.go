@@ -3,4 +3,7 @@ package util
 type QueryResult struct {
 	Columns []string
 	Records [][]interface{}
+	// NextPageToken resumes the query after the last record, when there may be more records.
+	// See FireQL.ExecutePage.
+	NextPageToken string
 }
@@ -0,0 +1,45 @@
+package fireql
+
+import (
+	selectStmt "github.com/pgollangi/fireql/pkg/select"
+	"github.com/pgollangi/fireql/pkg/util"
+	"github.com/xwb1989/sqlparser"
+)
+
+// Stmt is a SELECT query prepared by FireQL.Prepare. The query is parsed and compiled
+// into a Firestore query once, and can be executed many times, also concurrently,
+// with different values of its parameters.
+type Stmt struct {
+	prepared *selectStmt.Prepared
+}
+
+// Prepare parses and compiles the SELECT query, which may have ? and :name parameters
+// in place of values, e.g.
+//
+//	stmt, err := fql.Prepare("SELECT name FROM users WHERE age > ? AND city = :city")
+//	result, err := stmt.Execute(18, sql.Named("city", "Paris"))
+//
+// Options such as the default limit apply as of preparing the query.
+// The statement must be closed when it's no longer used.
+func (fql *FireQL) Prepare(query string) (*Stmt, error) {
+	if sqlparser.Preview(query) != sqlparser.StmtSelect {
+		return nil, util.NewUnsupportedError(util.CodeUnsupportedStatement, leadingKeyword(query),
+			"only SELECT queries can be prepared")
+	}
+	prepared, err := selectStmt.New(fql.context, query).Prepare()
+	if err != nil {
+		return nil, err
+	}
+	return &Stmt{prepared: prepared}, nil
+}
+
+// Execute runs the query with values of its parameters. ? parameters take args in order,
+// :name parameters take args passed as sql.NamedArg, e.g. sql.Named("city", "Paris").
+func (stmt *Stmt) Execute(args ...interface{}) (*util.QueryResult, error) {
+	return stmt.prepared.Execute(args...)
+}
+
+// Close releases the Firestore client of the statement.
+func (stmt *Stmt) Close() error {
+	return stmt.prepared.Close()
+}